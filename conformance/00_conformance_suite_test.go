@@ -1,22 +1,141 @@
 package conformance
 
 import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
 	"testing"
-
-	g "github.com/onsi/ginkgo"
-	"github.com/onsi/ginkgo/reporters"
-	. "github.com/onsi/gomega"
 )
 
 func TestConformance(t *testing.T) {
-	g.Describe(suiteDescription, func() {
-		test01Pull()
-		test02Push()
-		test03ContentDiscovery()
-		test04ContentManagement()
-	})
-
-	RegisterFailHandler(g.Fail)
-	reporters := []g.Reporter{newHTMLReporter(reportHTMLFilename), reporters.NewJUnitReporter(reportJUnitFilename)}
-	g.RunSpecsWithDefaultAndCustomReporters(t, suiteDescription, reporters)
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := readModes(); err != nil {
+		t.Fatal(err)
+	}
+
+	if sweepMode {
+		runSweep(t, cfg)
+		return
+	}
+	if fuzzMode {
+		runFuzz(t, cfg)
+		return
+	}
+	if diffBaselineFilename != "" {
+		runDiff(t)
+		return
+	}
+	if bundleVerifyFilename != "" {
+		runVerifyBundle(t)
+		return
+	}
+
+	results, err := Run(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Isolate {
+		fmt.Printf("isolated run: repository %s, tag prefix %s\n", results.Namespace, results.TagPrefix)
+	}
+	if len(results.Failed()) > 0 {
+		t.Fail()
+	}
+}
+
+func runSweep(t *testing.T, cfg Config) {
+	// the leftovers of an isolated run are swept by pointing OCI_NAMESPACE
+	// at its repository and setting its tag prefix
+	cfg.Isolate = false
+	if err := setup(cfg); err != nil {
+		t.Fatal(err)
+	}
+	namespaces := []string{client.Config.DefaultName}
+	if cfg.CrossmountNamespace != "" {
+		namespaces = append(namespaces, crossmountNamespace)
+	}
+
+	for _, namespace := range namespaces {
+		removed := sweep(namespace, sweepTagPrefix)
+		for _, r := range removed {
+			t.Logf("removed %s", r)
+		}
+		t.Logf("swept %s: %d leftover resources removed", namespace, len(removed))
+	}
+}
+
+func runFuzz(t *testing.T, cfg Config) {
+	if err := setup(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("fuzzing with %s=%d", envVarFuzzSeed, fuzzSeed)
+	r := rand.New(rand.NewSource(fuzzSeed))
+
+	for i := 0; i < fuzzSequences; i++ {
+		sizes := map[int]int{}
+		for b := 0; b < fuzzBlobs; b++ {
+			sizes[b] = 2 + r.Intn(64)
+		}
+		ops := generateFuzzSequence(r, fuzzLength, sizes)
+
+		run := newFuzzRun(sizes)
+		err := run.run(ops)
+		tracker.unwind()
+		if err == nil {
+			continue
+		}
+
+		ops, run, err = shrinkFuzzSequence(ops, sizes, run, err)
+		var steps []string
+		for _, op := range ops {
+			steps = append(steps, op.String())
+		}
+		t.Fatalf("sequence %d contradicts the expected registry state: %v\n\noperations:\n  %s\n\nrequests:\n  %s",
+			i, err, strings.Join(steps, "\n  "), strings.Join(run.requests, "\n  "))
+	}
+	t.Logf("%d sequences of %d operations passed", fuzzSequences, fuzzLength)
+}
+
+func runDiff(t *testing.T) {
+	baseline, err := readResults(diffBaselineFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := readResults(diffResultsFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := diffResults(baseline, results, diffTimingThreshold)
+	fmt.Printf("Comparing %s with %s\n\n", diffResultsFilename, diffBaselineFilename)
+	d.print(os.Stdout)
+	if d.Regressions() > 0 {
+		t.Fail()
+	}
+}
+
+func runVerifyBundle(t *testing.T) {
+	manifest, signature, err := verifyBundle(bundleVerifyFilename, bundlePublicKeyFile)
+	if err != nil {
+		t.Fatalf("%s: %v", bundleVerifyFilename, err)
+	}
+	fmt.Printf("%s: %d files verified\n", bundleVerifyFilename, len(manifest.Files))
+	fmt.Printf("suite version %s, spec version %s, created %s\n", manifest.SuiteVersion, manifest.SpecVersion, manifest.Created)
+	for _, status := range []string{statusPassed, statusFailed, statusSkipped, statusWaived, statusBlocked} {
+		fmt.Printf("  %s: %d\n", status, manifest.Summary[status])
+	}
+	switch signature {
+	case bundleUnsigned:
+		fmt.Println("the bundle is not signed")
+	case bundleSignatureUnverified:
+		// the key in the bundle proves nothing, as whoever changed the
+		// bundle could have replaced it too
+		t.Errorf("signature NOT verified: set %s to the trusted public key of the submitter", envVarBundlePublicKey)
+	case bundleSignatureVerified:
+		fmt.Printf("signature verified with %s\n", bundlePublicKeyFile)
+	}
 }
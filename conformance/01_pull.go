@@ -2,7 +2,7 @@ package conformance
 
 import (
 	"net/http"
-	"os"
+	"strconv"
 
 	"github.com/bloodorangeio/reggie"
 	g "github.com/onsi/ginkgo"
@@ -13,46 +13,75 @@ var test01Pull = func() {
 	g.Context(titlePull, func() {
 
 		var tag string
+		var blobPushed, layerPushed, manifestPushed *dependency
+
+		// expectMatchingGet issues the GET request matching a HEAD request and
+		// checks that the HEAD response has the same status, Content-Type and
+		// Docker-Content-Digest as the GET, and the length of its body as
+		// Content-Length, as clients commonly resolve content by HEAD and then
+		// fetch it by GET. The client drops any body sent with a HEAD
+		// response, so that cannot be checked here.
+		expectMatchingGet := func(head *reggie.Response) {
+			u := head.Request.RawRequest.URL
+			req := client.NewRequest(reggie.GET, u.RequestURI()).
+				SetHeader("Accept", head.Request.Header.Get("Accept"))
+			resp, err := client.Do(req)
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode()).To(Equal(head.StatusCode()))
+			if resp.StatusCode() != http.StatusOK {
+				return
+			}
+
+			Expect(head.Header().Get("Content-Length")).To(Equal(strconv.Itoa(len(resp.Body()))), "Content-Length")
+			Expect(head.Header().Get("Content-Type")).To(Equal(resp.Header().Get("Content-Type")), "Content-Type")
+			Expect(head.Header().Get("Docker-Content-Digest")).To(Equal(resp.Header().Get("Docker-Content-Digest")), "Docker-Content-Digest")
+		}
 
 		g.Context("Setup", func() {
-			g.Specify("Populate registry with test blob", func() {
+			blobPushed = specifyDependency("Populate registry with test blob", func() *reggie.Response {
 				SkipIfDisabled(pull)
 				RunOnlyIf(runPullSetup)
 				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
-				resp, _ := client.Do(req)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
 				req = client.NewRequest(reggie.PUT, resp.GetRelativeLocation()).
 					SetQueryParam("digest", configs[0].Digest).
 					SetHeader("Content-Type", "application/octet-stream").
 					SetHeader("Content-Length", configs[0].ContentLength).
 					SetBody(configs[0].Content)
-				resp, err := client.Do(req)
+				resp, err = client.Do(req)
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(SatisfyAll(
 					BeNumerically(">=", 200),
 					BeNumerically("<", 300)))
+				return resp
 			})
 
-			g.Specify("Populate registry with test layer", func() {
+			layerPushed = specifyDependency("Populate registry with test layer", func() *reggie.Response {
 				SkipIfDisabled(pull)
 				RunOnlyIf(runPullSetup)
 				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
-				resp, _ := client.Do(req)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
 				req = client.NewRequest(reggie.PUT, resp.GetRelativeLocation()).
 					SetQueryParam("digest", layerBlobDigest).
 					SetHeader("Content-Type", "application/octet-stream").
 					SetHeader("Content-Length", layerBlobContentLength).
 					SetBody(layerBlobData)
-				resp, err := client.Do(req)
+				resp, err = client.Do(req)
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(SatisfyAll(
 					BeNumerically(">=", 200),
 					BeNumerically("<", 300)))
+				return resp
 			})
 
-			g.Specify("Populate registry with test manifest", func() {
+			manifestPushed = specifyDependency("Populate registry with test manifest", func() *reggie.Response {
 				SkipIfDisabled(pull)
 				RunOnlyIf(runPullSetup)
-				tag := testTagName
+				requires(blobPushed)
+				requires(layerPushed)
+				tag := runTag(testTagName)
 				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
 					reggie.WithReference(tag)).
 					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
@@ -62,16 +91,19 @@ var test01Pull = func() {
 				Expect(resp.StatusCode()).To(SatisfyAll(
 					BeNumerically(">=", 200),
 					BeNumerically("<", 300)))
+				return resp
 			})
 
 			g.Specify("Get the name of a tag", func() {
 				SkipIfDisabled(pull)
 				RunOnlyIf(runPullSetup)
+				requires(manifestPushed)
 				req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list")
 				resp, _ := client.Do(req)
 				tag = getTagNameFromResponse(resp)
 
-				// attempt to forcibly overwrite this tag with the unique manifest for this run
+				// attempt to forcibly overwrite this tag with the unique manifest for this run;
+				// whether tags can be moved is checked by the Tag Mutability workflow
 				req = client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
 					reggie.WithReference(tag)).
 					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
@@ -82,7 +114,17 @@ var test01Pull = func() {
 			g.Specify("Get tag name from environment", func() {
 				SkipIfDisabled(pull)
 				RunOnlyIfNot(runPullSetup)
-				tag = os.Getenv(envVarTagName)
+				tag = existingTagName
+			})
+		})
+
+		g.Context("Base API", func() {
+			g.Specify("GET request to the base API endpoint should yield 200 response", func() {
+				SkipIfDisabled(pull)
+				req := client.NewRequest(reggie.GET, "/v2/")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
 			})
 		})
 
@@ -94,15 +136,18 @@ var test01Pull = func() {
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(Equal(http.StatusNotFound))
+				expectMatchingGet(resp)
 			})
 
 			g.Specify("HEAD request to existing blob should yield 200", func() {
 				SkipIfDisabled(pull)
+				requiresIf(runPullSetup, blobPushed)
 				req := client.NewRequest(reggie.HEAD, "/v2/<name>/blobs/<digest>",
 					reggie.WithDigest(configs[0].Digest))
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				expectMatchingGet(resp)
 				if h := resp.Header().Get("Docker-Content-Digest"); h != "" {
 					Expect(h).To(Equal(configs[0].Digest))
 				}
@@ -119,6 +164,7 @@ var test01Pull = func() {
 
 			g.Specify("GET request to existing blob URL should yield 200", func() {
 				SkipIfDisabled(pull)
+				requiresIf(runPullSetup, blobPushed)
 				req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>", reggie.WithDigest(configs[0].Digest))
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
@@ -134,15 +180,18 @@ var test01Pull = func() {
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(Equal(http.StatusNotFound))
+				expectMatchingGet(resp)
 			})
 
 			g.Specify("HEAD request to manifest path (digest) should yield 200 response", func() {
 				SkipIfDisabled(pull)
+				requiresIf(runPullSetup, manifestPushed)
 				req := client.NewRequest(reggie.HEAD, "/v2/<name>/manifests/<digest>", reggie.WithDigest(manifests[0].Digest)).
 					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				expectMatchingGet(resp)
 				if h := resp.Header().Get("Docker-Content-Digest"); h != "" {
 					Expect(h).To(Equal(manifests[0].Digest))
 				}
@@ -150,12 +199,14 @@ var test01Pull = func() {
 
 			g.Specify("HEAD request to manifest path (tag) should yield 200 response", func() {
 				SkipIfDisabled(pull)
+				requiresIf(runPullSetup, manifestPushed)
 				Expect(tag).ToNot(BeEmpty())
-				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>", reggie.WithReference(tag)).
+				req := client.NewRequest(reggie.HEAD, "/v2/<name>/manifests/<reference>", reggie.WithReference(tag)).
 					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				expectMatchingGet(resp)
 				if h := resp.Header().Get("Docker-Content-Digest"); h != "" {
 					Expect(h).To(Equal(manifests[0].Digest))
 				}
@@ -172,6 +223,7 @@ var test01Pull = func() {
 
 			g.Specify("GET request to manifest path (digest) should yield 200 response", func() {
 				SkipIfDisabled(pull)
+				requiresIf(runPullSetup, manifestPushed)
 				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<digest>", reggie.WithDigest(manifests[0].Digest)).
 					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
 				resp, err := client.Do(req)
@@ -181,6 +233,7 @@ var test01Pull = func() {
 
 			g.Specify("GET request to manifest path (tag) should yield 200 response", func() {
 				SkipIfDisabled(pull)
+				requiresIf(runPullSetup, manifestPushed)
 				Expect(tag).ToNot(BeEmpty())
 				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>", reggie.WithReference(tag)).
 					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
@@ -0,0 +1,698 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bloodorangeio/reggie"
+	g "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var test02Push = func() {
+	g.Context(titlePush, func() {
+
+		g.Context("Setup", func() {
+			// No setup required at this time for push tests
+		})
+
+		g.Context("Blob Upload Streamed", func() {
+			streamedPatch := specifyDependency("PATCH request with blob in body should yield 202 response", func() *reggie.Response {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+
+				req = client.NewRequest(reggie.PATCH, resp.GetRelativeLocation()).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetBody(testBlobA)
+				resp, err = client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusAccepted))
+				return resp
+			})
+
+			g.Specify("PUT request to session URL with digest should yield 201 response", func() {
+				SkipIfDisabled(push)
+				patch := requires(streamedPatch)
+				req := client.NewRequest(reggie.PUT, patch.GetRelativeLocation()).
+					SetQueryParam("digest", testBlobADigest).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetHeader("Content-Length", testBlobALength)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+			})
+		})
+
+		g.Context("Blob Upload Monolithic", func() {
+			g.Specify("GET nonexistent blob should result in 404 response", func() {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>",
+					reggie.WithDigest(dummyDigest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusNotFound))
+			})
+
+			monolithicPost := specifyDependency("POST request with digest and blob should yield a 201 or 202", func() *reggie.Response {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/").
+					SetHeader("Content-Length", configs[1].ContentLength).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetQueryParam("digest", configs[1].Digest).
+					SetBody(configs[1].Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+				Expect(resp.StatusCode()).To(SatisfyAny(
+					Equal(http.StatusCreated),
+					Equal(http.StatusAccepted),
+				))
+				return resp
+			})
+
+			g.Specify("GET request to blob URL from prior request should yield 200 or 404 based on response code", func() {
+				SkipIfDisabled(push)
+				post := requires(monolithicPost)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>", reggie.WithDigest(configs[1].Digest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				if post.StatusCode() == http.StatusAccepted {
+					Expect(resp.StatusCode()).To(Equal(http.StatusNotFound))
+				} else {
+					Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				}
+			})
+
+			sessionPut := specifyDependency("PUT upload of a blob to the session from a 202 response should yield 201", func() *reggie.Response {
+				SkipIfDisabled(push)
+				post := requires(monolithicPost)
+				RunOnlyIf(post.StatusCode() == http.StatusAccepted)
+				req := client.NewRequest(reggie.PUT, post.GetRelativeLocation()).
+					SetHeader("Content-Length", configs[1].ContentLength).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetQueryParam("digest", configs[1].Digest).
+					SetBody(configs[1].Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+				if h := resp.Header().Get("Docker-Content-Digest"); h != "" {
+					Expect(h).To(Equal(configs[1].Digest))
+				}
+				return resp
+			})
+
+			g.Specify("GET request to blob uploaded through the session from a 202 response should yield 200", func() {
+				SkipIfDisabled(push)
+				post := requires(monolithicPost)
+				RunOnlyIf(post.StatusCode() == http.StatusAccepted)
+				requires(sessionPut)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>", reggie.WithDigest(configs[1].Digest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				Expect(resp.Body()).To(Equal(configs[1].Content))
+			})
+
+			sessionPost := specifyDependency("POST request should yield a session ID", func() *reggie.Response {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusAccepted))
+				return resp
+			})
+
+			g.Specify("PUT upload of a blob should yield a 201 Response", func() {
+				SkipIfDisabled(push)
+				post := requires(sessionPost)
+				req := client.NewRequest(reggie.PUT, post.GetRelativeLocation()).
+					SetHeader("Content-Length", configs[1].ContentLength).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetQueryParam("digest", configs[1].Digest).
+					SetBody(configs[1].Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+			})
+
+			g.Specify("GET request to existing blob should yield 200 response", func() {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>", reggie.WithDigest(configs[1].Digest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+			})
+
+			g.Specify("PUT upload of a layer blob should yield a 201 Response", func() {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				req = client.NewRequest(reggie.PUT, resp.GetRelativeLocation()).
+					SetHeader("Content-Length", layerBlobContentLength).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetQueryParam("digest", layerBlobDigest).
+					SetBody(layerBlobData)
+				resp, err = client.Do(req)
+				Expect(err).To(BeNil())
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+			})
+
+			g.Specify("GET request to existing layer should yield 200 response", func() {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>", reggie.WithDigest(layerBlobDigest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+			})
+		})
+
+		g.Context("Blob Upload Chunked", func() {
+			g.Specify("Out-of-order blob upload should return 416", func() {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/").
+					SetHeader("Content-Length", "0")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+
+				req = client.NewRequest(reggie.PATCH, resp.GetRelativeLocation()).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetHeader("Content-Length", testBlobBChunk2Length).
+					SetHeader("Content-Range", testBlobBChunk2Range).
+					SetBody(testBlobBChunk2)
+				resp, err = client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusRequestedRangeNotSatisfiable))
+			})
+
+			firstChunk := specifyDependency("PATCH request with first chunk should return 202", func() *reggie.Response {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/").
+					SetHeader("Content-Length", "0")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+
+				req = client.NewRequest(reggie.PATCH, resp.GetRelativeLocation()).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetHeader("Content-Length", testBlobBChunk1Length).
+					SetHeader("Content-Range", testBlobBChunk1Range).
+					SetBody(testBlobBChunk1)
+				resp, err = client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusAccepted))
+				return resp
+			})
+
+			g.Specify("PUT request with final chunk should return 201", func() {
+				SkipIfDisabled(push)
+				patch := requires(firstChunk)
+				req := client.NewRequest(reggie.PUT, patch.GetRelativeLocation()).
+					SetHeader("Content-Length", testBlobBChunk2Length).
+					SetHeader("Content-Range", testBlobBChunk2Range).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetQueryParam("digest", testBlobBDigest).
+					SetBody(testBlobBChunk2)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+			})
+		})
+
+		g.Context("Cross-Repository Blob Mount", func() {
+			mount := specifyDependency("POST request to mount another repository's blob should return 201 or 202", func() *reggie.Response {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/",
+					reggie.WithName(crossmountNamespace)).
+					SetQueryParam("mount", testBlobADigest).
+					SetQueryParam("from", client.Config.DefaultName)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(SatisfyAny(
+					Equal(http.StatusCreated),
+					Equal(http.StatusAccepted),
+				))
+				Expect(resp.GetRelativeLocation()).To(ContainSubstring(crossmountNamespace))
+				return resp
+			})
+
+			g.Specify("GET request to test digest within cross-mount namespace should return 200", func() {
+				SkipIfDisabled(push)
+				post := requires(mount)
+				RunOnlyIf(post.StatusCode() == http.StatusCreated)
+
+				req := client.NewRequest(reggie.GET, post.GetRelativeLocation())
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+			})
+
+			g.Specify("Cross-mounting of nonexistent blob should yield session id", func() {
+				SkipIfDisabled(push)
+				post := requires(mount)
+				RunOnlyIf(post.StatusCode() == http.StatusAccepted)
+
+				loc := post.GetRelativeLocation()
+				Expect(loc).To(ContainSubstring("/blobs/uploads/"))
+			})
+
+			// expectUsableSession checks that a mount which could not be
+			// honoured fell back to an upload session, and that the session
+			// can be used to upload the blob normally
+			expectUsableSession := func(resp *reggie.Response, blob *TestBlob) {
+				Expect(resp.StatusCode()).To(Equal(http.StatusAccepted))
+				loc := resp.GetRelativeLocation()
+				Expect(loc).To(ContainSubstring("/blobs/uploads/"))
+				Expect(loc).To(ContainSubstring(crossmountNamespace))
+
+				req := client.NewRequest(reggie.PUT, loc).
+					SetQueryParam("digest", blob.Digest).
+					SetHeader("Content-Type", "application/octet-stream").
+					SetHeader("Content-Length", blob.ContentLength).
+					SetBody(blob.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				Expect(resp.Header().Get("Location")).ToNot(BeEmpty())
+
+				req = client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>",
+					reggie.WithName(crossmountNamespace), reggie.WithDigest(blob.Digest))
+				resp, err = client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				Expect(resp.Body()).To(Equal(blob.Content))
+			}
+
+			// a mount names the repository to mount from (end-11); without
+			// it, the request is one to open an upload session (end-4a),
+			// which returns 202
+			g.Specify("Cross-mounting without the `from` parameter should return a usable 202 session", func() {
+				SkipIfDisabled(push)
+				blob := newTestBlob(randomBlob(64))
+				Expect(uploadBlobMonolithic(blob)).To(Succeed())
+
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/",
+					reggie.WithName(crossmountNamespace)).
+					SetQueryParam("mount", blob.Digest)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				expectUsableSession(resp, blob)
+			})
+
+			g.Specify("Cross-mounting from a nonexistent repository should return a usable 202 session", func() {
+				SkipIfDisabled(push)
+				blob := newTestBlob(randomBlob(64))
+				Expect(uploadBlobMonolithic(blob)).To(Succeed())
+
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/",
+					reggie.WithName(crossmountNamespace)).
+					SetQueryParam("mount", blob.Digest).
+					SetQueryParam("from", nonexistentNamespace)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				expectUsableSession(resp, blob)
+			})
+
+			g.Specify("Cross-mounting of a digest which exists nowhere should return a usable 202 session", func() {
+				SkipIfDisabled(push)
+				blob := newTestBlob(randomBlob(64))
+
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/",
+					reggie.WithName(crossmountNamespace)).
+					SetQueryParam("mount", blob.Digest).
+					SetQueryParam("from", client.Config.DefaultName)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				expectUsableSession(resp, blob)
+			})
+
+			g.Specify("Cross-mounting from a repository that cannot be read should return 202 and not mount the blob", func() {
+				SkipIfDisabled(push)
+				RunOnlyIf(crossmountUnreadableNamespace != "" && crossmountUnreadableDigest != "")
+				req := client.NewRequest(reggie.HEAD, "/v2/<name>/blobs/<digest>",
+					reggie.WithName(crossmountNamespace), reggie.WithDigest(crossmountUnreadableDigest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				if resp.StatusCode() == http.StatusOK {
+					g.Skip(fmt.Sprintf("%s already exists in %s", crossmountUnreadableDigest, crossmountNamespace))
+				}
+
+				req = client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/",
+					reggie.WithName(crossmountNamespace)).
+					SetQueryParam("mount", crossmountUnreadableDigest).
+					SetQueryParam("from", crossmountUnreadableNamespace)
+				session, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(session.StatusCode()).To(Equal(http.StatusAccepted))
+
+				resp, err = client.Do(client.NewRequest(reggie.HEAD, "/v2/<name>/blobs/<digest>",
+					reggie.WithName(crossmountNamespace), reggie.WithDigest(crossmountUnreadableDigest)))
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusNotFound))
+
+				// the blob is not ours to upload, so use the session for another
+				expectUsableSession(session, newTestBlob(randomBlob(64)))
+			})
+		})
+
+		g.Context("Manifest Upload", func() {
+			g.Specify("GET nonexistent manifest should return 404", func() {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(nonexistentManifest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusNotFound))
+			})
+
+			g.Specify("PUT should accept a manifest upload", func() {
+				SkipIfDisabled(push)
+				for i := 0; i < 4; i++ {
+					tag := runTag(fmt.Sprintf("test%d", i))
+					req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+						reggie.WithReference(tag)).
+						SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+						SetBody(manifests[1].Content)
+					resp, err := client.Do(req)
+					Expect(err).To(BeNil())
+					location := resp.Header().Get("Location")
+					Expect(location).ToNot(BeEmpty())
+					Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				}
+			})
+
+			g.Specify("Registry should accept a manifest upload with no layers", func() {
+				SkipIfDisabled(push)
+				RunOnlyIfNot(skipEmptyLayerTest)
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(emptyLayerTestTag))).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(emptyLayerManifestContent)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				location := resp.Header().Get("Location")
+				Expect(location).ToNot(BeEmpty())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+			})
+
+			// expectManifestRejected checks that a manifest PUT was refused
+			// with MANIFEST_BLOB_UNKNOWN or MANIFEST_INVALID, and that an error
+			// identifies the offending digest. The spec does not say which of
+			// the two a registry must return for a missing or mismatched blob.
+			expectManifestRejected := func(resp *reggie.Response, digest string) {
+				Expect(resp.StatusCode()).To(SatisfyAll(
+					BeNumerically(">=", 400),
+					BeNumerically("<", 500)))
+				errs, err := getErrors(resp)
+				Expect(err).To(BeNil())
+				Expect(errs).ToNot(BeEmpty())
+				var codes []string
+				identified := false
+				for _, e := range errs {
+					codes = append(codes, e.Code)
+					identified = identified || e.mentions(digest)
+				}
+				Expect(codes).To(ContainElement(BeElementOf(
+					errorCodes[MANIFEST_BLOB_UNKNOWN],
+					errorCodes[MANIFEST_INVALID])))
+				Expect(identified).To(BeTrue(), "no error identifies %s", digest)
+			}
+
+			// putManifest pushes content under a new tag
+			putManifest := func(content []byte) *reggie.Response {
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(randomTag())).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				return resp
+			}
+
+			g.Specify("PUT manifest referencing a nonexistent layer should return MANIFEST_BLOB_UNKNOWN or MANIFEST_INVALID", func() {
+				SkipIfDisabled(push)
+				config := newTestBlob([]byte(fmt.Sprintf(`{"author":%q}`, randomString(16))))
+				Expect(uploadBlobMonolithic(config)).To(Succeed())
+				layer := newTestBlob(randomBlob(64))
+
+				resp := putManifest(newManifest(imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.config.v1+json",
+					Digest:    godigest.Digest(config.Digest),
+					Size:      int64(len(config.Content)),
+				}, imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+					Digest:    godigest.Digest(layer.Digest),
+					Size:      int64(len(layer.Content)),
+				}))
+				expectManifestRejected(resp, layer.Digest)
+			})
+
+			g.Specify("PUT manifest referencing a nonexistent config should return MANIFEST_BLOB_UNKNOWN or MANIFEST_INVALID", func() {
+				SkipIfDisabled(push)
+				config := newTestBlob([]byte(fmt.Sprintf(`{"author":%q}`, randomString(16))))
+				layer := newTestBlob(randomBlob(64))
+				Expect(uploadBlobMonolithic(layer)).To(Succeed())
+
+				resp := putManifest(newManifest(imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.config.v1+json",
+					Digest:    godigest.Digest(config.Digest),
+					Size:      int64(len(config.Content)),
+				}, imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+					Digest:    godigest.Digest(layer.Digest),
+					Size:      int64(len(layer.Content)),
+				}))
+				expectManifestRejected(resp, config.Digest)
+			})
+
+			g.Specify("PUT manifest with a layer size that disagrees with the stored blob should return MANIFEST_BLOB_UNKNOWN or MANIFEST_INVALID", func() {
+				SkipIfDisabled(push)
+				config := newTestBlob([]byte(fmt.Sprintf(`{"author":%q}`, randomString(16))))
+				Expect(uploadBlobMonolithic(config)).To(Succeed())
+				layer := newTestBlob(randomBlob(64))
+				Expect(uploadBlobMonolithic(layer)).To(Succeed())
+
+				resp := putManifest(newManifest(imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.config.v1+json",
+					Digest:    godigest.Digest(config.Digest),
+					Size:      int64(len(config.Content)),
+				}, imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+					Digest:    godigest.Digest(layer.Digest),
+					Size:      int64(len(layer.Content)) + 1,
+				}))
+				expectManifestRejected(resp, layer.Digest)
+			})
+
+			// untagged is pushed by digest only, as is done for the children
+			// of an image index
+			untaggedConfig := newTestBlob([]byte(fmt.Sprintf(`{"author":%q}`, randomString(16))))
+			untaggedLayer := newTestBlob(randomBlob(64))
+			untagged := newTestBlob(newManifest(imagespec.Descriptor{
+				MediaType: "application/vnd.oci.image.config.v1+json",
+				Digest:    godigest.Digest(untaggedConfig.Digest),
+				Size:      int64(len(untaggedConfig.Content)),
+			}, imagespec.Descriptor{
+				MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+				Digest:    godigest.Digest(untaggedLayer.Digest),
+				Size:      int64(len(untaggedLayer.Content)),
+			}))
+
+			untaggedPushed := specifyDependency("PUT manifest by digest should return 201 with a matching Docker-Content-Digest", func() *reggie.Response {
+				SkipIfDisabled(push)
+				Expect(uploadBlobMonolithic(untaggedConfig)).To(Succeed())
+				Expect(uploadBlobMonolithic(untaggedLayer)).To(Succeed())
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<digest>",
+					reggie.WithDigest(untagged.Digest)).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(untagged.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				Expect(resp.Header().Get("Location")).ToNot(BeEmpty())
+				Expect(resp.Header().Get("Docker-Content-Digest")).To(Equal(untagged.Digest))
+				return resp
+			})
+
+			g.Specify("PUT manifest to a digest that does not match its content should return DIGEST_INVALID or 400", func() {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<digest>",
+					reggie.WithDigest(dummyDigest)).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(untagged.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(SatisfyAll(
+					BeNumerically(">=", 400),
+					BeNumerically("<", 500)))
+				if resp.StatusCode() != http.StatusBadRequest {
+					errs, err := getErrors(resp)
+					Expect(err).To(BeNil())
+					var codes []string
+					for _, e := range errs {
+						codes = append(codes, e.Code)
+					}
+					Expect(codes).To(ContainElement(errorCodes[DIGEST_INVALID]))
+				}
+			})
+
+			g.Specify("GET manifest pushed by digest should return its content", func() {
+				SkipIfDisabled(push)
+				requires(untaggedPushed)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<digest>",
+					reggie.WithDigest(untagged.Digest)).
+					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				Expect(resp.Body()).To(Equal(untagged.Content))
+				Expect(resp.Header().Get("Docker-Content-Digest")).To(SatisfyAny(
+					BeEmpty(),
+					Equal(untagged.Digest)))
+			})
+
+			g.Specify("Manifests with unusual formatting should be returned byte-for-byte", func() {
+				SkipIfDisabled(push)
+				config := newTestBlob([]byte(fmt.Sprintf(`{"author":%q}`, randomString(16))))
+				Expect(uploadBlobMonolithic(config)).To(Succeed())
+				layer := newTestBlob(randomBlob(64))
+				Expect(uploadBlobMonolithic(layer)).To(Succeed())
+
+				// valid manifests which a registry re-serializing them could
+				// not reproduce: unsorted keys, irregular whitespace, unicode
+				// escapes and annotations the registry does not know about
+				contents := []string{
+					fmt.Sprintf("{\"layers\":[{\"size\":%d,\"digest\":\"%s\",\"mediaType\":\"application/vnd.oci.image.layer.v1.tar+gzip\"}],\"config\":{\"size\":%d,\"mediaType\":\"application/vnd.oci.image.config.v1+json\",\"digest\":\"%s\"},\"schemaVersion\":2}",
+						len(layer.Content), layer.Digest, len(config.Content), config.Digest),
+					fmt.Sprintf("{\r\n  \"schemaVersion\" :  2 ,\n\t\"mediaType\": \"application/vnd.oci.image.manifest.v1+json\",\n\t\"config\": { \"mediaType\": \"application/vnd.oci.image.config.v1+json\", \"digest\": \"%s\", \"size\": %d },\n\t\"layers\": [\n\t\t{\"mediaType\":\"application/vnd.oci.image.layer.v1.tar+gzip\",   \"digest\": \"%s\",\"size\":%d}\n\t],\n\t\"annotations\": { \"org.example.z\": \"caf\\u00e9 \\u2603\", \"org.example.a\": \"\\/\\\"quoted\\\"\", \"org.example.m\": \"%s\" }\n}\n\n",
+						config.Digest, len(config.Content), layer.Digest, len(layer.Content), randomString(16)),
+				}
+
+				for _, content := range contents {
+					manifest := newTestBlob([]byte(content))
+					tag := randomTag()
+					req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+						reggie.WithReference(tag)).
+						SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+						SetBody(manifest.Content)
+					resp, err := client.Do(req)
+					Expect(err).To(BeNil())
+					Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+					Expect(resp.Header().Get("Docker-Content-Digest")).To(SatisfyAny(
+						BeEmpty(),
+						Equal(manifest.Digest)))
+
+					for _, reference := range []string{tag, manifest.Digest} {
+						req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+							reggie.WithReference(reference)).
+							SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+						resp, err := client.Do(req)
+						Expect(err).To(BeNil())
+						Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+						Expect(string(resp.Body())).To(Equal(content))
+						Expect(godigest.FromBytes(resp.Body()).String()).To(Equal(manifest.Digest))
+						Expect(resp.Header().Get("Docker-Content-Digest")).To(SatisfyAny(
+							BeEmpty(),
+							Equal(manifest.Digest)))
+					}
+				}
+			})
+
+			g.Specify("GET request to manifest URL (digest) should yield 200 response", func() {
+				SkipIfDisabled(push)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<digest>", reggie.WithDigest(manifests[1].Digest)).
+					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+			})
+		})
+
+		g.Context("Teardown", func() {
+			if deleteManifestBeforeBlobs {
+				g.Specify("Delete manifest created in tests", func() {
+					SkipIfDisabled(push)
+					RunOnlyIf(runPushSetup)
+					req := client.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<digest>", reggie.WithDigest(manifests[1].Digest))
+					resp, err := client.Do(req)
+					Expect(err).To(BeNil())
+					Expect(resp.StatusCode()).To(SatisfyAny(
+						SatisfyAll(
+							BeNumerically(">=", 200),
+							BeNumerically("<", 300),
+						),
+						Equal(http.StatusMethodNotAllowed),
+					))
+				})
+			}
+
+			g.Specify("Delete config blob created in tests", func() {
+				SkipIfDisabled(push)
+				RunOnlyIf(runPushSetup)
+				req := client.NewRequest(reggie.DELETE, "/v2/<name>/blobs/<digest>", reggie.WithDigest(configs[1].Digest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(SatisfyAny(
+					SatisfyAll(
+						BeNumerically(">=", 200),
+						BeNumerically("<", 300),
+					),
+					Equal(http.StatusMethodNotAllowed),
+				))
+			})
+
+			g.Specify("Delete layer blob created in setup", func() {
+				SkipIfDisabled(push)
+				RunOnlyIf(runPushSetup)
+				req := client.NewRequest(reggie.DELETE, "/v2/<name>/blobs/<digest>", reggie.WithDigest(layerBlobDigest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(SatisfyAny(
+					SatisfyAll(
+						BeNumerically(">=", 200),
+						BeNumerically("<", 300),
+					),
+					Equal(http.StatusMethodNotAllowed),
+				))
+			})
+
+			if !deleteManifestBeforeBlobs {
+				g.Specify("Delete manifest created in tests", func() {
+					SkipIfDisabled(push)
+					RunOnlyIf(runPushSetup)
+					req := client.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<digest>", reggie.WithDigest(manifests[1].Digest))
+					resp, err := client.Do(req)
+					Expect(err).To(BeNil())
+					Expect(resp.StatusCode()).To(SatisfyAny(
+						SatisfyAll(
+							BeNumerically(">=", 200),
+							BeNumerically("<", 300),
+						),
+						Equal(http.StatusMethodNotAllowed),
+					))
+				})
+			}
+
+		})
+	})
+}
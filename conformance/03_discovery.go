@@ -3,9 +3,7 @@ package conformance
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
-	"strings"
 
 	"github.com/bloodorangeio/reggie"
 	g "github.com/onsi/ginkgo"
@@ -17,9 +15,10 @@ var test03ContentDiscovery = func() {
 
 		var numTags = 4
 		var tagList []string
+		var blobPushed, layerPushed, tagsPushed, listed *dependency
 
 		g.Context("Setup", func() {
-			g.Specify("Populate registry with test blob", func() {
+			blobPushed = specifyDependency("Populate registry with test blob", func() *reggie.Response {
 				SkipIfDisabled(contentDiscovery)
 				RunOnlyIf(runContentDiscoverySetup)
 				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
@@ -35,9 +34,10 @@ var test03ContentDiscovery = func() {
 				Expect(resp.StatusCode()).To(SatisfyAll(
 					BeNumerically(">=", 200),
 					BeNumerically("<", 300)))
+				return resp
 			})
 
-			g.Specify("Populate registry with test layer", func() {
+			layerPushed = specifyDependency("Populate registry with test layer", func() *reggie.Response {
 				SkipIfDisabled(contentDiscovery)
 				RunOnlyIf(runContentDiscoverySetup)
 				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
@@ -53,13 +53,16 @@ var test03ContentDiscovery = func() {
 				Expect(resp.StatusCode()).To(SatisfyAll(
 					BeNumerically(">=", 200),
 					BeNumerically("<", 300)))
+				return resp
 			})
 
-			g.Specify("Populate registry with test tags", func() {
+			tagsPushed = specifyDependency("Populate registry with test tags", func() *reggie.Response {
 				SkipIfDisabled(contentDiscovery)
 				RunOnlyIf(runContentDiscoverySetup)
+				requires(blobPushed)
+				requires(layerPushed)
 				for i := 0; i < numTags; i++ {
-					tag := fmt.Sprintf("test%d", i)
+					tag := runTag(fmt.Sprintf("test%d", i))
 					tagList = append(tagList, tag)
 					req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
 						reggie.WithReference(tag)).
@@ -73,29 +76,33 @@ var test03ContentDiscovery = func() {
 				}
 				req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list")
 				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
 				tagList = getTagList(resp)
-				_ = err
+				return resp
 			})
 
 			g.Specify("Populate registry with test tags (no push)", func() {
 				SkipIfDisabled(contentDiscovery)
 				RunOnlyIfNot(runContentDiscoverySetup)
-				tagList = strings.Split(os.Getenv(envVarTagList), ",")
+				tagList = existingTagList
 			})
 		})
 
 		g.Context("Test content discovery endpoints", func() {
-			g.Specify("GET request to list tags should yield 200 response", func() {
+			listed = specifyDependency("GET request to list tags should yield 200 response", func() *reggie.Response {
 				SkipIfDisabled(contentDiscovery)
+				requiresIf(runContentDiscoverySetup, tagsPushed)
 				req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list")
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
-				numTags = len(tagList)
+				numTags = len(ownTags(tagList))
+				return resp
 			})
 
 			g.Specify("GET number of tags should be limitable by `n` query parameter", func() {
 				SkipIfDisabled(contentDiscovery)
+				requires(listed)
 				numResults := numTags / 2
 				req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list").
 					SetQueryParam("n", strconv.Itoa(numResults))
@@ -109,6 +116,7 @@ var test03ContentDiscovery = func() {
 
 			g.Specify("GET start of tag is set by `last` query parameter", func() {
 				SkipIfDisabled(contentDiscovery)
+				requires(listed)
 				numResults := numTags / 2
 				req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list").
 					SetQueryParam("n", strconv.Itoa(numResults))
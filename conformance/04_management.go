@@ -15,9 +15,10 @@ var test04ContentManagement = func() {
 		const defaultTagName = "tagtest0"
 		var tagToDelete string
 		var numTags int
+		var configPushed, layerPushed, tagPushed, counted, blobsDeleted *dependency
 
 		g.Context("Setup", func() {
-			g.Specify("Populate registry with test config blob", func() {
+			configPushed = specifyDependency("Populate registry with test config blob", func() *reggie.Response {
 				SkipIfDisabled(contentManagement)
 				RunOnlyIf(runContentManagementSetup)
 				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
@@ -33,9 +34,10 @@ var test04ContentManagement = func() {
 				Expect(resp.StatusCode()).To(SatisfyAll(
 					BeNumerically(">=", 200),
 					BeNumerically("<", 300)))
+				return resp
 			})
 
-			g.Specify("Populate registry with test layer", func() {
+			layerPushed = specifyDependency("Populate registry with test layer", func() *reggie.Response {
 				SkipIfDisabled(contentManagement)
 				RunOnlyIf(runContentManagementSetup)
 				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
@@ -51,12 +53,15 @@ var test04ContentManagement = func() {
 				Expect(resp.StatusCode()).To(SatisfyAll(
 					BeNumerically(">=", 200),
 					BeNumerically("<", 300)))
+				return resp
 			})
 
-			g.Specify("Populate registry with test tag", func() {
+			tagPushed = specifyDependency("Populate registry with test tag", func() *reggie.Response {
 				SkipIfDisabled(contentManagement)
 				RunOnlyIf(runContentManagementSetup)
-				tagToDelete = defaultTagName
+				requires(configPushed)
+				requires(layerPushed)
+				tagToDelete = runTag(defaultTagName)
 				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
 					reggie.WithReference(tagToDelete)).
 					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
@@ -66,11 +71,13 @@ var test04ContentManagement = func() {
 				Expect(resp.StatusCode()).To(SatisfyAll(
 					BeNumerically(">=", 200),
 					BeNumerically("<", 300)))
+				return resp
 			})
 
-			g.Specify("Check how many tags there are before anything gets deleted", func() {
+			counted = specifyDependency("Check how many tags there are before anything gets deleted", func() *reggie.Response {
 				SkipIfDisabled(contentManagement)
 				RunOnlyIf(runContentManagementSetup)
+				requires(tagPushed)
 				req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list")
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
@@ -79,13 +86,15 @@ var test04ContentManagement = func() {
 				jsonData := []byte(resp.String())
 				err = json.Unmarshal(jsonData, tagList)
 				Expect(err).To(BeNil())
-				numTags = len(tagList.Tags)
+				numTags = len(ownTags(tagList.Tags))
+				return resp
 			})
 		})
 
 		g.Context("Manifest delete", func() {
 			g.Specify("DELETE request to manifest tag should return 202, unless tag deletion is disallowed (400/405)", func() {
 				SkipIfDisabled(contentManagement)
+				requiresIf(runContentManagementSetup, tagPushed)
 				req := client.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<reference>",
 					reggie.WithReference(tagToDelete))
 				resp, err := client.Do(req)
@@ -104,6 +113,7 @@ var test04ContentManagement = func() {
 
 			g.Specify("DELETE request to manifest (digest) should yield 202 response unless already deleted", func() {
 				SkipIfDisabled(contentManagement)
+				requiresIf(runContentManagementSetup, tagPushed)
 				req := client.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<digest>", reggie.WithDigest(manifests[3].Digest))
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
@@ -116,6 +126,7 @@ var test04ContentManagement = func() {
 
 			g.Specify("GET request to deleted manifest URL should yield 404 response, unless delete is disallowed", func() {
 				SkipIfDisabled(contentManagement)
+				requiresIf(runContentManagementSetup, tagPushed)
 				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<digest>", reggie.WithDigest(manifests[3].Digest))
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
@@ -127,6 +138,7 @@ var test04ContentManagement = func() {
 
 			g.Specify("GET request to tags list should reflect manifest deletion", func() {
 				SkipIfDisabled(contentManagement)
+				requiresIf(runContentManagementSetup, counted)
 				req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list")
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
@@ -135,14 +147,16 @@ var test04ContentManagement = func() {
 				jsonData := []byte(resp.String())
 				err = json.Unmarshal(jsonData, tagList)
 				Expect(err).To(BeNil())
-				Expect(len(tagList.Tags)).To(BeNumerically("<", numTags))
+				Expect(len(ownTags(tagList.Tags))).To(BeNumerically("<", numTags))
 			})
 		})
 
 		g.Context("Blob delete", func() {
-			g.Specify("DELETE request to blob URL should yield 202 response", func() {
+			blobsDeleted = specifyDependency("DELETE request to blob URL should yield 202 response", func() *reggie.Response {
 				SkipIfDisabled(contentManagement)
 				RunOnlyIf(runContentManagementSetup)
+				requires(configPushed)
+				requires(layerPushed)
 				// config blob
 				req := client.NewRequest(reggie.DELETE, "/v2/<name>/blobs/<digest>", reggie.WithDigest(configs[3].Digest))
 				resp, err := client.Do(req)
@@ -154,11 +168,13 @@ var test04ContentManagement = func() {
 				Expect(err).To(BeNil())
 				Expect(err).To(BeNil())
 				Expect(resp.StatusCode()).To(Equal(http.StatusAccepted))
+				return resp
 			})
 
 			g.Specify("GET request to deleted blob URL should yield 404 response", func() {
 				SkipIfDisabled(contentManagement)
 				RunOnlyIf(runContentManagementSetup)
+				requires(blobsDeleted)
 				req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>", reggie.WithDigest(configs[3].Digest))
 				resp, err := client.Do(req)
 				Expect(err).To(BeNil())
@@ -167,8 +183,8 @@ var test04ContentManagement = func() {
 		})
 
 		g.Context("Teardown", func() {
-			// TODO: delete blob+tag?
-			// No teardown required at this time for content management tests
+			// No teardown required at this time for content management tests;
+			// anything left behind is removed by the resource tracker
 		})
 	})
 }
@@ -0,0 +1,169 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bloodorangeio/reggie"
+	g "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var test05Benchmark = func() {
+	g.Context(titleBenchmark, func() {
+
+		// blobs holds the content pushed during setup for each blob size
+		blobs := map[int64]*TestBlob{}
+		var blobsPushed, manifestPushed *dependency
+
+		g.Context("Setup", func() {
+			blobsPushed = specifyDependency("Populate registry with benchmark blobs", func() *reggie.Response {
+				SkipIfDisabled(benchmark)
+				for _, size := range benchmarkBlobSizes {
+					blob := newTestBlob(randomBlob(size))
+					Expect(uploadBlobMonolithic(blob)).To(Succeed())
+					blobs[size] = blob
+				}
+				return nil
+			})
+
+			manifestPushed = specifyDependency("Populate registry with benchmark manifest", func() *reggie.Response {
+				SkipIfDisabled(benchmark)
+				requires(blobsPushed)
+				config := imagespec.Image{
+					Architecture: "amd64",
+					OS:           "linux",
+					RootFS: imagespec.RootFS{
+						Type:    "layers",
+						DiffIDs: []godigest.Digest{},
+					},
+					Author: randomString(16),
+				}
+				configContent, err := json.MarshalIndent(&config, "", "\t")
+				Expect(err).To(BeNil())
+				configBlob := newTestBlob(configContent)
+				Expect(uploadBlobMonolithic(configBlob)).To(Succeed())
+
+				manifest := imagespec.Manifest{
+					Config: imagespec.Descriptor{
+						MediaType: "application/vnd.oci.image.config.v1+json",
+						Digest:    godigest.Digest(configBlob.Digest),
+						Size:      int64(len(configBlob.Content)),
+					},
+					Layers: []imagespec.Descriptor{},
+				}
+				manifest.SchemaVersion = 2
+				for _, size := range benchmarkBlobSizes {
+					blob, ok := blobs[size]
+					Expect(ok).To(BeTrue())
+					manifest.Layers = append(manifest.Layers, imagespec.Descriptor{
+						MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+						Digest:    godigest.Digest(blob.Digest),
+						Size:      size,
+					})
+				}
+				manifestContent, err := json.MarshalIndent(&manifest, "", "\t")
+				Expect(err).To(BeNil())
+
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(benchmarkTagName))).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(manifestContent)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(SatisfyAll(
+					BeNumerically(">=", 200),
+					BeNumerically("<", 300)))
+				return resp
+			})
+		})
+
+		g.Context("Blob Download", func() {
+			for _, size := range benchmarkBlobSizes {
+				size := size
+
+				g.Specify(fmt.Sprintf("HEAD request to existing blob (%s)", formatSize(size)), func() {
+					SkipIfDisabled(benchmark)
+					requires(blobsPushed)
+					blob, ok := blobs[size]
+					Expect(ok).To(BeTrue())
+					result := runBenchmark("HEAD /v2/<name>/blobs/<digest>", size, false, func(int, *TestBlob) (int64, error) {
+						req := client.NewRequest(reggie.HEAD, "/v2/<name>/blobs/<digest>",
+							reggie.WithDigest(blob.Digest))
+						resp, err := client.Do(req)
+						return 0, checkStatus(resp, err, http.StatusOK)
+					})
+					Expect(result.Errors).To(Equal(0), result.FirstError)
+				})
+
+				g.Specify(fmt.Sprintf("GET request to existing blob (%s)", formatSize(size)), func() {
+					SkipIfDisabled(benchmark)
+					requires(blobsPushed)
+					blob, ok := blobs[size]
+					Expect(ok).To(BeTrue())
+					result := runBenchmark("GET /v2/<name>/blobs/<digest>", size, false, func(int, *TestBlob) (int64, error) {
+						req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>",
+							reggie.WithDigest(blob.Digest))
+						resp, err := client.Do(req)
+						if err := checkStatus(resp, err, http.StatusOK); err != nil {
+							return 0, err
+						}
+						return int64(len(resp.Body())), nil
+					})
+					Expect(result.Errors).To(Equal(0), result.FirstError)
+				})
+			}
+		})
+
+		g.Context("Blob Upload", func() {
+			for _, size := range benchmarkBlobSizes {
+				size := size
+
+				g.Specify(fmt.Sprintf("Monolithic blob upload (%s)", formatSize(size)), func() {
+					SkipIfDisabled(benchmark)
+					result := runBenchmark("POST+PUT /v2/<name>/blobs/uploads/", size, true, func(_ int, blob *TestBlob) (int64, error) {
+						return size, uploadBlobMonolithic(blob)
+					})
+					Expect(result.Errors).To(Equal(0), result.FirstError)
+				})
+
+				g.Specify(fmt.Sprintf("Chunked blob upload (%s)", formatSize(size)), func() {
+					SkipIfDisabled(benchmark)
+					result := runBenchmark("POST+PATCH+PUT /v2/<name>/blobs/uploads/", size, true, func(_ int, blob *TestBlob) (int64, error) {
+						return size, uploadBlobChunked(blob, benchmarkChunkSize)
+					})
+					Expect(result.Errors).To(Equal(0), result.FirstError)
+				})
+			}
+		})
+
+		g.Context("Manifests and Tags", func() {
+			g.Specify("GET request to manifest path (tag)", func() {
+				SkipIfDisabled(benchmark)
+				requires(manifestPushed)
+				result := runBenchmark("GET /v2/<name>/manifests/<reference>", 0, false, func(int, *TestBlob) (int64, error) {
+					req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+						reggie.WithReference(runTag(benchmarkTagName))).
+						SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+					resp, err := client.Do(req)
+					return 0, checkStatus(resp, err, http.StatusOK)
+				})
+				Expect(result.Errors).To(Equal(0), result.FirstError)
+			})
+
+			g.Specify("GET request to list tags", func() {
+				SkipIfDisabled(benchmark)
+				requires(manifestPushed)
+				result := runBenchmark("GET /v2/<name>/tags/list", 0, false, func(int, *TestBlob) (int64, error) {
+					req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list")
+					resp, err := client.Do(req)
+					return 0, checkStatus(resp, err, http.StatusOK)
+				})
+				Expect(result.Errors).To(Equal(0), result.FirstError)
+			})
+		})
+	})
+}
@@ -0,0 +1,155 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bloodorangeio/reggie"
+	g "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var test06TagMutability = func() {
+	g.Context(titleTagMutability, func() {
+
+		// original is tagged first, then the tag is moved to replacement
+		var original, replacement *TestBlob
+
+		// immutableReason is set when the registry refuses to move the tag
+		var immutableReason string
+
+		// the manifests are pushed, then the first one tagged
+		var populated, tagged, retagged *dependency
+
+		// getTag fetches the manifest currently referred to by the tag
+		getTag := func() *reggie.Response {
+			req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+				reggie.WithReference(runTag(mutableTagName))).
+				SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+			resp, err := client.Do(req)
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+			return resp
+		}
+
+		// isImmutable reports whether a refused retag says that tags cannot
+		// be moved, rather than that the request was wrong
+		isImmutable := func(resp *reggie.Response) bool {
+			switch resp.StatusCode() {
+			case http.StatusConflict, http.StatusMethodNotAllowed:
+				return true
+			}
+			errs, _ := getErrors(resp)
+			for _, e := range errs {
+				switch e.Code {
+				case errorCodes[DENIED], errorCodes[TAG_INVALID], errorCodes[UNSUPPORTED]:
+					return true
+				}
+			}
+			return false
+		}
+
+		g.Context("Setup", func() {
+			populated = specifyDependency("Populate registry with two manifests", func() *reggie.Response {
+				SkipIfDisabled(tagMutability)
+				var manifests []*TestBlob
+				var resp *reggie.Response
+				for i := 0; i < 2; i++ {
+					config := newTestBlob([]byte(fmt.Sprintf(`{"author":%q}`, randomString(16))))
+					Expect(uploadBlobMonolithic(config)).To(Succeed())
+					layer := newTestBlob(randomBlob(64))
+					Expect(uploadBlobMonolithic(layer)).To(Succeed())
+					manifest := newTestBlob(newManifest(imagespec.Descriptor{
+						MediaType: "application/vnd.oci.image.config.v1+json",
+						Digest:    godigest.Digest(config.Digest),
+						Size:      int64(len(config.Content)),
+					}, imagespec.Descriptor{
+						MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+						Digest:    godigest.Digest(layer.Digest),
+						Size:      int64(len(layer.Content)),
+					}))
+					req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<digest>",
+						reggie.WithDigest(manifest.Digest)).
+						SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+						SetBody(manifest.Content)
+					var err error
+					resp, err = client.Do(req)
+					Expect(err).To(BeNil())
+					Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+					manifests = append(manifests, manifest)
+				}
+				original, replacement = manifests[0], manifests[1]
+				return resp
+			})
+
+			tagged = specifyDependency("Tag the first manifest", func() *reggie.Response {
+				SkipIfDisabled(tagMutability)
+				requires(populated)
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(mutableTagName))).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(original.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				return resp
+			})
+		})
+
+		g.Context("Retagging", func() {
+			retagged = specifyDependency("PUT request moving an existing tag to another manifest should yield 201, unless tags are immutable", func() *reggie.Response {
+				SkipIfDisabled(tagMutability)
+				requires(tagged)
+				immutableReason = ""
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(mutableTagName))).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(replacement.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				if isImmutable(resp) {
+					immutableReason = fmt.Sprintf("the registry refused to move an existing tag (status %d)", resp.StatusCode())
+					if errs, err := getErrors(resp); err == nil && len(errs) > 0 {
+						immutableReason = fmt.Sprintf("%s: %s %s", immutableReason, errs[0].Code, errs[0].Message)
+					}
+					g.Skip(fmt.Sprintf("capability: tags are immutable; %s", immutableReason))
+				}
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				return resp
+			})
+
+			g.Specify("GET request to moved tag should return the new manifest", func() {
+				SkipIfDisabled(tagMutability)
+				RunOnlyIf(immutableReason == "")
+				requires(retagged)
+				resp := getTag()
+				Expect(resp.Body()).To(Equal(replacement.Content))
+				Expect(resp.Header().Get("Docker-Content-Digest")).To(SatisfyAny(
+					BeEmpty(),
+					Equal(replacement.Digest)))
+			})
+
+			g.Specify("GET request to immutable tag should still return the original manifest", func() {
+				SkipIfDisabled(tagMutability)
+				requires(tagged)
+				RunOnlyIf(immutableReason != "")
+				resp := getTag()
+				Expect(resp.Body()).To(Equal(original.Content))
+			})
+
+			g.Specify("GET request to previously tagged manifest by digest should yield 200", func() {
+				SkipIfDisabled(tagMutability)
+				requires(populated)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<digest>",
+					reggie.WithDigest(original.Digest)).
+					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				Expect(resp.Body()).To(Equal(original.Content))
+			})
+		})
+	})
+}
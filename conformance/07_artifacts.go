@@ -0,0 +1,169 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bloodorangeio/reggie"
+	g "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type (
+	// testArtifact is a manifest whose config and layers are not those of a
+	// container image, along with the blobs it refers to.
+	testArtifact struct {
+		Name            string
+		Tag             string
+		ConfigMediaType string
+		Config          *TestBlob
+		LayerMediaType  string
+		Layer           *TestBlob
+		Manifest        *TestBlob
+		Rejected        string
+		Pushed          *dependency
+	}
+)
+
+var test07Artifacts = func() {
+	g.Context(titleArtifacts, func() {
+
+		testArtifacts := []*testArtifact{{
+			Name:            "SBOM",
+			Tag:             runTag(artifactTagPrefix) + "sbom",
+			ConfigMediaType: "application/vnd.oci.empty.v1+json",
+			Config:          newTestBlob([]byte("{}")),
+			LayerMediaType:  "application/spdx+json",
+			Layer:           newTestBlob([]byte(fmt.Sprintf(`{"spdxVersion":"SPDX-2.3","name":%q}`, randomString(16)))),
+		}, {
+			Name:            "signature",
+			Tag:             runTag(artifactTagPrefix) + "signature",
+			ConfigMediaType: "application/vnd.oci.empty.v1+json",
+			Config:          newTestBlob([]byte("{}")),
+			LayerMediaType:  "application/vnd.dev.cosign.simplesigning.v1+json",
+			Layer:           newTestBlob([]byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":%q}}}`, randomString(16)))),
+		}, {
+			Name:            "zstd layer",
+			Tag:             runTag(artifactTagPrefix) + "zstd",
+			ConfigMediaType: "application/vnd.oci.image.config.v1+json",
+			Config:          newTestBlob([]byte(fmt.Sprintf(`{"architecture":"amd64","os":"linux","author":%q,"rootfs":{"type":"layers","diff_ids":[]}}`, randomString(16)))),
+			LayerMediaType:  "application/vnd.oci.image.layer.v1.tar+zstd",
+			Layer:           newTestBlob(randomBlob(64)),
+		}, {
+			Name:            "custom config",
+			Tag:             runTag(artifactTagPrefix) + "custom",
+			ConfigMediaType: "application/vnd.example.artifact.config.v1+json",
+			Config:          newTestBlob([]byte(fmt.Sprintf(`{"name":%q}`, randomString(16)))),
+			LayerMediaType:  "application/vnd.example.artifact.layer.v1+octet-stream",
+			Layer:           newTestBlob(randomBlob(64)),
+		}}
+
+		for _, a := range testArtifacts {
+			a.Manifest = newTestBlob(newManifest(imagespec.Descriptor{
+				MediaType: a.ConfigMediaType,
+				Digest:    godigest.Digest(a.Config.Digest),
+				Size:      int64(len(a.Config.Content)),
+			}, imagespec.Descriptor{
+				MediaType: a.LayerMediaType,
+				Digest:    godigest.Digest(a.Layer.Digest),
+				Size:      int64(len(a.Layer.Content)),
+			}))
+		}
+
+		var populated *dependency
+
+		g.Context("Setup", func() {
+			populated = specifyDependency("Populate registry with artifact blobs", func() *reggie.Response {
+				SkipIfDisabled(artifacts)
+				for _, a := range testArtifacts {
+					Expect(uploadBlobMonolithic(a.Config)).To(Succeed())
+					Expect(uploadBlobMonolithic(a.Layer)).To(Succeed())
+				}
+				return nil
+			})
+		})
+
+		g.Context("Artifact Push", func() {
+			for _, a := range testArtifacts {
+				a := a
+
+				a.Pushed = specifyDependency(fmt.Sprintf("PUT request with %s manifest should yield 201 response", a.Name), func() *reggie.Response {
+					SkipIfDisabled(artifacts)
+					requires(populated)
+					req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+						reggie.WithReference(a.Tag)).
+						SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+						SetBody(a.Manifest.Content)
+					resp, err := client.Do(req)
+					Expect(err).To(BeNil())
+					if resp.StatusCode() >= 400 && resp.StatusCode() < 500 {
+						if errs, err := getErrors(resp); err == nil {
+							for _, e := range errs {
+								if e.Code == errorCodes[MANIFEST_INVALID] || e.Code == errorCodes[UNSUPPORTED] {
+									a.Rejected = e.Code
+									g.Skip(fmt.Sprintf("capability: the registry rejected config media type %s with layer media type %s (%s)",
+										a.ConfigMediaType, a.LayerMediaType, a.Rejected))
+								}
+							}
+						}
+					}
+					Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+					return resp
+				})
+			}
+		})
+
+		g.Context("Artifact Pull", func() {
+			for _, a := range testArtifacts {
+				a := a
+
+				g.Specify(fmt.Sprintf("GET request to %s manifest should return it unchanged", a.Name), func() {
+					SkipIfDisabled(artifacts)
+					RunOnlyIf(a.Rejected == "")
+					requires(a.Pushed)
+					req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+						reggie.WithReference(a.Tag)).
+						SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+					resp, err := client.Do(req)
+					Expect(err).To(BeNil())
+					Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+					Expect(resp.Body()).To(Equal(a.Manifest.Content))
+				})
+
+				g.Specify(fmt.Sprintf("GET request to %s blobs should return them unchanged", a.Name), func() {
+					SkipIfDisabled(artifacts)
+					RunOnlyIf(a.Rejected == "")
+					requires(a.Pushed)
+					for _, blob := range []*TestBlob{a.Config, a.Layer} {
+						req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>",
+							reggie.WithDigest(blob.Digest))
+						resp, err := client.Do(req)
+						Expect(err).To(BeNil())
+						Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+						Expect(resp.Body()).To(Equal(blob.Content))
+					}
+				})
+			}
+		})
+
+		g.Context("Artifact Discovery", func() {
+			g.Specify("GET request to list tags should include every accepted artifact", func() {
+				SkipIfDisabled(artifacts)
+				requires(populated)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				tags := getTagList(resp)
+				// artifacts which failed to push are reported by their push
+				for _, a := range testArtifacts {
+					if a.Pushed.passed {
+						Expect(tags).To(ContainElement(a.Tag))
+					}
+				}
+			})
+		})
+	})
+}
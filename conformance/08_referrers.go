@@ -0,0 +1,282 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bloodorangeio/reggie"
+	g "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type (
+	// referrerDescriptor is a descriptor with the artifactType field, which
+	// is not part of the image-spec version used by the suite.
+	referrerDescriptor struct {
+		MediaType    string            `json:"mediaType"`
+		ArtifactType string            `json:"artifactType,omitempty"`
+		Digest       string            `json:"digest"`
+		Size         int64             `json:"size"`
+		Annotations  map[string]string `json:"annotations,omitempty"`
+	}
+
+	// referrerManifest is an image manifest with an artifactType and a
+	// subject, the manifest it refers to.
+	referrerManifest struct {
+		SchemaVersion int                    `json:"schemaVersion"`
+		MediaType     string                 `json:"mediaType"`
+		ArtifactType  string                 `json:"artifactType"`
+		Config        imagespec.Descriptor   `json:"config"`
+		Layers        []imagespec.Descriptor `json:"layers"`
+		Subject       *imagespec.Descriptor  `json:"subject,omitempty"`
+	}
+
+	// referrersIndex is the image index returned by the referrers API, and
+	// pushed by clients under the referrers tag schema.
+	referrersIndex struct {
+		SchemaVersion int                  `json:"schemaVersion"`
+		MediaType     string               `json:"mediaType"`
+		Manifests     []referrerDescriptor `json:"manifests"`
+	}
+)
+
+const (
+	signatureArtifactType = "application/vnd.example.signature.v1+json"
+	sbomArtifactType      = "application/spdx+json"
+	emptyConfigMediaType  = "application/vnd.oci.empty.v1+json"
+)
+
+var test08Referrers = func() {
+	g.Context(titleReferrers, func() {
+
+		// subject is the image which the referrers refer to
+		var subject *TestBlob
+
+		// pushedReferrers are the artifacts pushed with subject as their subject
+		var pushedReferrers []referrerDescriptor
+
+		// tagSchemaReason is set when the registry does not implement the
+		// referrers API, and the tag schema must be used instead
+		var tagSchemaReason string
+
+		// the subject is pushed, then the artifacts referring to it, which are
+		// then listed by the referrers API if the registry implements it
+		var subjectPushed, referrersPushed, apiListed *dependency
+
+		emptyConfig := newTestBlob([]byte("{}"))
+
+		// getReferrers lists the referrers of the subject via the referrers
+		// API, with the given artifactType filter if it is not empty
+		getReferrers := func(artifactType string) *reggie.Response {
+			req := client.NewRequest(reggie.GET, "/v2/<name>/referrers/<digest>",
+				reggie.WithDigest(subject.Digest))
+			if artifactType != "" {
+				req.SetQueryParam("artifactType", artifactType)
+			}
+			resp, err := client.Do(req)
+			Expect(err).To(BeNil())
+			return resp
+		}
+
+		// decodeIndex checks that resp holds an image index and decodes it
+		decodeIndex := func(resp *reggie.Response) referrersIndex {
+			Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Content-Type")).To(HavePrefix("application/vnd.oci.image.index.v1+json"))
+			var index referrersIndex
+			Expect(json.Unmarshal(resp.Body(), &index)).To(Succeed())
+			return index
+		}
+
+		// expectReferrer checks that manifests lists the artifact r, which is
+		// found by its digest so that a mismatch in another field is named
+		expectReferrer := func(manifests []referrerDescriptor, r referrerDescriptor) {
+			var found *referrerDescriptor
+			for i := range manifests {
+				if manifests[i].Digest == r.Digest {
+					found = &manifests[i]
+					break
+				}
+			}
+			Expect(found).ToNot(BeNil(), "%s is not listed", r.Digest)
+			Expect(found.ArtifactType).To(Equal(r.ArtifactType), "artifactType of %s", r.Digest)
+			Expect(found.Size).To(Equal(r.Size), "size of %s", r.Digest)
+			Expect(found.MediaType).To(Equal(r.MediaType), "mediaType of %s", r.Digest)
+		}
+
+		g.Context("Setup", func() {
+			subjectPushed = specifyDependency("Populate registry with subject image", func() *reggie.Response {
+				SkipIfDisabled(referrers)
+				config := newTestBlob([]byte(fmt.Sprintf(`{"author":%q}`, randomString(16))))
+				Expect(uploadBlobMonolithic(config)).To(Succeed())
+				layer := newTestBlob(randomBlob(64))
+				Expect(uploadBlobMonolithic(layer)).To(Succeed())
+				subject = newTestBlob(newManifest(imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.config.v1+json",
+					Digest:    godigest.Digest(config.Digest),
+					Size:      int64(len(config.Content)),
+				}, imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+					Digest:    godigest.Digest(layer.Digest),
+					Size:      int64(len(layer.Content)),
+				}))
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<digest>",
+					reggie.WithDigest(subject.Digest)).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(subject.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				return resp
+			})
+
+			referrersPushed = specifyDependency("Populate registry with artifacts referring to the subject", func() *reggie.Response {
+				SkipIfDisabled(referrers)
+				requires(subjectPushed)
+				pushedReferrers = nil
+				var resp *reggie.Response
+				Expect(uploadBlobMonolithic(emptyConfig)).To(Succeed())
+				for _, artifactType := range []string{signatureArtifactType, sbomArtifactType} {
+					layer := newTestBlob([]byte(fmt.Sprintf(`{"artifactType":%q,"id":%q}`, artifactType, randomString(16))))
+					Expect(uploadBlobMonolithic(layer)).To(Succeed())
+					manifest := referrerManifest{
+						SchemaVersion: 2,
+						MediaType:     "application/vnd.oci.image.manifest.v1+json",
+						ArtifactType:  artifactType,
+						Config: imagespec.Descriptor{
+							MediaType: emptyConfigMediaType,
+							Digest:    godigest.Digest(emptyConfig.Digest),
+							Size:      int64(len(emptyConfig.Content)),
+						},
+						Layers: []imagespec.Descriptor{{
+							MediaType: artifactType,
+							Digest:    godigest.Digest(layer.Digest),
+							Size:      int64(len(layer.Content)),
+						}},
+						Subject: &imagespec.Descriptor{
+							MediaType: "application/vnd.oci.image.manifest.v1+json",
+							Digest:    godigest.Digest(subject.Digest),
+							Size:      int64(len(subject.Content)),
+						},
+					}
+					content, err := json.MarshalIndent(&manifest, "", "\t")
+					Expect(err).To(BeNil())
+					artifact := newTestBlob(content)
+
+					req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<digest>",
+						reggie.WithDigest(artifact.Digest)).
+						SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+						SetBody(artifact.Content)
+					resp, err = client.Do(req)
+					Expect(err).To(BeNil())
+					Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+					pushedReferrers = append(pushedReferrers, referrerDescriptor{
+						MediaType:    "application/vnd.oci.image.manifest.v1+json",
+						ArtifactType: artifactType,
+						Digest:       artifact.Digest,
+						Size:         int64(len(artifact.Content)),
+					})
+				}
+				return resp
+			})
+		})
+
+		g.Context("Referrers API", func() {
+			apiListed = specifyDependency("GET request to referrers endpoint should list the artifacts referring to the subject", func() *reggie.Response {
+				SkipIfDisabled(referrers)
+				requires(referrersPushed)
+				resp := getReferrers("")
+				if resp.StatusCode() == http.StatusNotFound {
+					tagSchemaReason = "the registry does not implement the referrers API (404)"
+					g.Skip(fmt.Sprintf("capability: %s; the referrers tag schema is used instead", tagSchemaReason))
+				}
+				index := decodeIndex(resp)
+				for _, r := range pushedReferrers {
+					expectReferrer(index.Manifests, r)
+				}
+				return resp
+			})
+
+			g.Specify("GET request to referrers endpoint with artifactType filter should list only matching artifacts", func() {
+				SkipIfDisabled(referrers)
+				RunOnlyIf(tagSchemaReason == "")
+				requires(apiListed)
+				resp := getReferrers(signatureArtifactType)
+				index := decodeIndex(resp)
+				// the signature is pushed first
+				expectReferrer(index.Manifests, pushedReferrers[0])
+				if !strings.Contains(resp.Header().Get("OCI-Filters-Applied"), "artifactType") {
+					g.Skip("capability: the registry does not filter referrers by artifactType, which clients then do themselves")
+				}
+				for _, m := range index.Manifests {
+					Expect(m.ArtifactType).To(Equal(signatureArtifactType))
+				}
+			})
+
+			g.Specify("GET request to referrers endpoint of an unknown digest should return an empty list", func() {
+				SkipIfDisabled(referrers)
+				RunOnlyIf(tagSchemaReason == "")
+				requires(apiListed)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/referrers/<digest>",
+					reggie.WithDigest(dummyDigest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				index := decodeIndex(resp)
+				Expect(index.Manifests).To(BeEmpty())
+			})
+		})
+
+		g.Context("Referrers Tag Schema", func() {
+			// the referrers of a subject are kept in an index tagged with
+			// the algorithm and encoded digest of the subject
+			referrersTag := func() string {
+				return strings.Replace(subject.Digest, ":", "-", 1)
+			}
+
+			// requiresTagSchema skips the current spec unless the registry was
+			// found not to implement the referrers API, as blocked if that
+			// could not be found out
+			requiresTagSchema := func() {
+				if tagSchemaReason == "" {
+					requires(apiListed)
+				}
+				RunOnlyIf(tagSchemaReason != "")
+			}
+
+			g.Specify("PUT request of index to referrers tag should yield 201 response", func() {
+				SkipIfDisabled(referrers)
+				requiresTagSchema()
+				content, err := json.MarshalIndent(&referrersIndex{
+					SchemaVersion: 2,
+					MediaType:     "application/vnd.oci.image.index.v1+json",
+					Manifests:     pushedReferrers,
+				}, "", "\t")
+				Expect(err).To(BeNil())
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(referrersTag())).
+					SetHeader("Content-Type", "application/vnd.oci.image.index.v1+json").
+					SetBody(content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+			})
+
+			g.Specify("GET request to referrers tag should list the artifacts referring to the subject", func() {
+				SkipIfDisabled(referrers)
+				requiresTagSchema()
+				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(referrersTag())).
+					SetHeader("Accept", "application/vnd.oci.image.index.v1+json")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				index := decodeIndex(resp)
+				for _, r := range pushedReferrers {
+					expectReferrer(index.Manifests, r)
+				}
+			})
+		})
+	})
+}
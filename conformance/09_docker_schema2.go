@@ -0,0 +1,184 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/bloodorangeio/reggie"
+	g "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	dockerManifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+	dockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	dockerConfigMediaType       = "application/vnd.docker.container.image.v1+json"
+	dockerLayerMediaType        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+)
+
+type (
+	// dockerManifest is a Docker image manifest, version 2, schema 2.
+	dockerManifest struct {
+		SchemaVersion int                    `json:"schemaVersion"`
+		MediaType     string                 `json:"mediaType"`
+		Config        imagespec.Descriptor   `json:"config"`
+		Layers        []imagespec.Descriptor `json:"layers"`
+	}
+
+	// dockerManifestList is a Docker manifest list, the predecessor of the
+	// OCI image index.
+	dockerManifestList struct {
+		SchemaVersion int                        `json:"schemaVersion"`
+		MediaType     string                     `json:"mediaType"`
+		Manifests     []dockerManifestDescriptor `json:"manifests"`
+	}
+
+	dockerManifestDescriptor struct {
+		MediaType string              `json:"mediaType"`
+		Size      int64               `json:"size"`
+		Digest    string              `json:"digest"`
+		Platform  *imagespec.Platform `json:"platform,omitempty"`
+	}
+)
+
+var test09DockerSchema2 = func() {
+	g.Context(titleDockerSchema2, func() {
+
+		var manifest, manifestList *TestBlob
+
+		// the blobs are pushed and the manifests built, then the manifest and
+		// the manifest list are pushed
+		var populated, manifestPushed, listPushed *dependency
+
+		// getManifest fetches reference with the given Accept header, and
+		// checks that content is returned unchanged with its media type
+		getManifest := func(reference, accept string, content *TestBlob, mediaType string) {
+			req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+				reggie.WithReference(reference)).
+				SetHeader("Accept", accept)
+			resp, err := client.Do(req)
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+			Expect(resp.Header().Get("Content-Type")).To(Equal(mediaType))
+			Expect(resp.Body()).To(Equal(content.Content))
+			Expect(resp.Header().Get("Docker-Content-Digest")).To(SatisfyAny(
+				BeEmpty(),
+				Equal(content.Digest)))
+		}
+
+		g.Context("Setup", func() {
+			populated = specifyDependency("Populate registry with Docker image blobs", func() *reggie.Response {
+				SkipIfDisabled(dockerSchema2)
+				config := newTestBlob([]byte(fmt.Sprintf(`{"architecture":"amd64","os":"linux","author":%q,"rootfs":{"type":"layers","diff_ids":[]}}`, randomString(16))))
+				Expect(uploadBlobMonolithic(config)).To(Succeed())
+				layer := newTestBlob(layerBlobData)
+				Expect(uploadBlobMonolithic(layer)).To(Succeed())
+
+				content, err := json.MarshalIndent(&dockerManifest{
+					SchemaVersion: 2,
+					MediaType:     dockerManifestMediaType,
+					Config: imagespec.Descriptor{
+						MediaType: dockerConfigMediaType,
+						Digest:    godigest.Digest(config.Digest),
+						Size:      int64(len(config.Content)),
+					},
+					Layers: []imagespec.Descriptor{{
+						MediaType: dockerLayerMediaType,
+						Digest:    godigest.Digest(layer.Digest),
+						Size:      int64(len(layer.Content)),
+					}},
+				}, "", "   ")
+				Expect(err).To(BeNil())
+				manifest = newTestBlob(content)
+
+				content, err = json.MarshalIndent(&dockerManifestList{
+					SchemaVersion: 2,
+					MediaType:     dockerManifestListMediaType,
+					Manifests: []dockerManifestDescriptor{{
+						MediaType: dockerManifestMediaType,
+						Size:      int64(len(manifest.Content)),
+						Digest:    manifest.Digest,
+						Platform:  &imagespec.Platform{Architecture: "amd64", OS: "linux"},
+					}},
+				}, "", "   ")
+				Expect(err).To(BeNil())
+				manifestList = newTestBlob(content)
+				return nil
+			})
+		})
+
+		g.Context("Docker Manifest Push", func() {
+			manifestPushed = specifyDependency("PUT request with Docker schema 2 manifest should yield 201 response", func() *reggie.Response {
+				SkipIfDisabled(dockerSchema2)
+				requires(populated)
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(dockerManifestTagName))).
+					SetHeader("Content-Type", dockerManifestMediaType).
+					SetBody(manifest.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				return resp
+			})
+
+			// the manifest list refers to the manifest, which must be pushed
+			// first
+			listPushed = specifyDependency("PUT request with Docker manifest list should yield 201 response", func() *reggie.Response {
+				SkipIfDisabled(dockerSchema2)
+				requires(manifestPushed)
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(dockerManifestListTagName))).
+					SetHeader("Content-Type", dockerManifestListMediaType).
+					SetBody(manifestList.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				return resp
+			})
+		})
+
+		g.Context("Docker Manifest Pull", func() {
+			g.Specify("GET request to Docker schema 2 manifest should return it with its Content-Type", func() {
+				SkipIfDisabled(dockerSchema2)
+				requires(manifestPushed)
+				getManifest(runTag(dockerManifestTagName), dockerManifestMediaType, manifest, dockerManifestMediaType)
+			})
+
+			g.Specify("GET request to Docker schema 2 manifest by digest should return it with its Content-Type", func() {
+				SkipIfDisabled(dockerSchema2)
+				requires(manifestPushed)
+				getManifest(manifest.Digest, dockerManifestMediaType, manifest, dockerManifestMediaType)
+			})
+
+			g.Specify("GET request to Docker manifest list should return it with its Content-Type", func() {
+				SkipIfDisabled(dockerSchema2)
+				requires(listPushed)
+				getManifest(runTag(dockerManifestListTagName), dockerManifestListMediaType, manifestList, dockerManifestListMediaType)
+			})
+
+			g.Specify("GET request accepting OCI and Docker media types should return the Docker manifest unconverted", func() {
+				SkipIfDisabled(dockerSchema2)
+				requires(listPushed)
+				accept := "application/vnd.oci.image.manifest.v1+json, application/vnd.oci.image.index.v1+json, " +
+					dockerManifestMediaType + ", " + dockerManifestListMediaType
+				getManifest(runTag(dockerManifestTagName), accept, manifest, dockerManifestMediaType)
+				getManifest(runTag(dockerManifestListTagName), accept, manifestList, dockerManifestListMediaType)
+			})
+
+			g.Specify("HEAD request to Docker schema 2 manifest should return its Content-Type", func() {
+				SkipIfDisabled(dockerSchema2)
+				requires(manifestPushed)
+				req := client.NewRequest(reggie.HEAD, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(dockerManifestTagName))).
+					SetHeader("Accept", dockerManifestMediaType)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				Expect(resp.Header().Get("Content-Type")).To(Equal(dockerManifestMediaType))
+			})
+		})
+	})
+}
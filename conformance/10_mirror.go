@@ -0,0 +1,205 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/bloodorangeio/reggie"
+	g "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var test10Mirror = func() {
+	g.Context(titleMirror, func() {
+
+		// config, layer and manifest are seeded into the upstream registry
+		var config, layer, manifest *TestBlob
+
+		var populated, blobsPulled *dependency
+
+		// expectErrorCode checks that resp has an error body with one of the
+		// given error codes
+		expectErrorCode := func(resp *reggie.Response, codes ...string) {
+			errs, err := getErrors(resp)
+			Expect(err).To(BeNil())
+			Expect(errs).ToNot(BeEmpty())
+			var got []string
+			for _, e := range errs {
+				got = append(got, e.Code)
+			}
+			Expect(got).To(ContainElement(BeElementOf(codes)))
+		}
+
+		g.Context("Setup", func() {
+			populated = specifyDependency("Populate upstream registry with test image", func() *reggie.Response {
+				SkipIfDisabled(mirror)
+				config = newTestBlob([]byte(fmt.Sprintf(`{"author":%q}`, randomString(16))))
+				layer = newTestBlob(randomBlob(64))
+				Expect(uploadBlobMonolithicWith(upstreamClient, config)).To(Succeed())
+				Expect(uploadBlobMonolithicWith(upstreamClient, layer)).To(Succeed())
+				manifest = newTestBlob(newManifest(imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.config.v1+json",
+					Digest:    godigest.Digest(config.Digest),
+					Size:      int64(len(config.Content)),
+				}, imagespec.Descriptor{
+					MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+					Digest:    godigest.Digest(layer.Digest),
+					Size:      int64(len(layer.Content)),
+				}))
+
+				req := upstreamClient.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(mirrorTagName))).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(manifest.Content)
+				resp, err := upstreamClient.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusCreated))
+				return resp
+			})
+		})
+
+		g.Context("Pull Through", func() {
+			g.Specify("GET request to upstream manifest (tag) through the mirror should return the upstream content", func() {
+				SkipIfDisabled(mirror)
+				requires(populated)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(mirrorTagName))).
+					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				Expect(resp.Body()).To(Equal(manifest.Content))
+				Expect(resp.Header().Get("Docker-Content-Digest")).To(SatisfyAny(
+					BeEmpty(),
+					Equal(manifest.Digest)))
+			})
+
+			g.Specify("GET request to upstream manifest (digest) through the mirror should return the upstream content", func() {
+				SkipIfDisabled(mirror)
+				requires(populated)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<digest>",
+					reggie.WithDigest(manifest.Digest)).
+					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				Expect(godigest.FromBytes(resp.Body()).String()).To(Equal(manifest.Digest))
+			})
+
+			blobsPulled = specifyDependency("GET request to upstream blobs through the mirror should return the upstream content", func() *reggie.Response {
+				SkipIfDisabled(mirror)
+				requires(populated)
+				var resp *reggie.Response
+				for _, blob := range []*TestBlob{config, layer} {
+					req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>",
+						reggie.WithDigest(blob.Digest))
+					var err error
+					resp, err = client.Do(req)
+					Expect(err).To(BeNil())
+					Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+					Expect(godigest.FromBytes(resp.Body()).String()).To(Equal(blob.Digest))
+				}
+				return resp
+			})
+		})
+
+		g.Context("Caching", func() {
+			g.Specify("GET request to blob deleted upstream should still be served from the mirror cache", func() {
+				SkipIfDisabled(mirror)
+				// the mirror can only have cached what it has pulled through
+				requires(blobsPulled)
+				req := upstreamClient.NewRequest(reggie.DELETE, "/v2/<name>/blobs/<digest>",
+					reggie.WithDigest(layer.Digest))
+				resp, err := upstreamClient.Do(req)
+				Expect(err).To(BeNil())
+				if resp.StatusCode() < 200 || resp.StatusCode() >= 300 {
+					g.Skip(fmt.Sprintf("caching cannot be checked; the upstream registry did not delete the blob (status %d)", resp.StatusCode()))
+				}
+
+				req = client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>",
+					reggie.WithDigest(layer.Digest))
+				resp, err = client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusOK))
+				Expect(resp.Body()).To(Equal(layer.Content))
+			})
+		})
+
+		g.Context("Missing Upstream Content", func() {
+			g.Specify("GET request to manifest missing upstream should return 404 with MANIFEST_UNKNOWN or NAME_UNKNOWN", func() {
+				SkipIfDisabled(mirror)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(randomTag())).
+					SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusNotFound))
+				expectErrorCode(resp, errorCodes[MANIFEST_UNKNOWN], errorCodes[NAME_UNKNOWN])
+			})
+
+			g.Specify("GET request to blob missing upstream should return 404 with BLOB_UNKNOWN or NAME_UNKNOWN", func() {
+				SkipIfDisabled(mirror)
+				req := client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>",
+					reggie.WithDigest(dummyDigest))
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(Equal(http.StatusNotFound))
+				expectErrorCode(resp, errorCodes[BLOB_UNKNOWN], errorCodes[NAME_UNKNOWN])
+			})
+		})
+
+		g.Context("Read-Only Mirror", func() {
+			g.Specify("POST request to start a blob upload on the mirror should return DENIED, UNSUPPORTED or UNAUTHORIZED", func() {
+				SkipIfDisabled(mirror)
+				req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(SatisfyAll(
+					BeNumerically(">=", 400),
+					BeNumerically("<", 500)))
+				expectErrorCode(resp, errorCodes[DENIED], errorCodes[UNSUPPORTED], errorCodes[UNAUTHORIZED])
+			})
+
+			g.Specify("PUT request of a manifest to the mirror should return DENIED, UNSUPPORTED or UNAUTHORIZED", func() {
+				SkipIfDisabled(mirror)
+				requires(populated)
+				req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+					reggie.WithReference(runTag(mirrorTagName))).
+					SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+					SetBody(manifest.Content)
+				resp, err := client.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(SatisfyAll(
+					BeNumerically(">=", 400),
+					BeNumerically("<", 500)))
+				expectErrorCode(resp, errorCodes[DENIED], errorCodes[UNSUPPORTED], errorCodes[UNAUTHORIZED])
+			})
+		})
+
+		g.Context("Teardown", func() {
+			g.Specify("Delete test image from upstream registry", func() {
+				SkipIfDisabled(mirror)
+				requires(populated)
+				req := upstreamClient.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<digest>",
+					reggie.WithDigest(manifest.Digest))
+				resp, err := upstreamClient.Do(req)
+				Expect(err).To(BeNil())
+				Expect(resp.StatusCode()).To(SatisfyAny(
+					SatisfyAll(
+						BeNumerically(">=", 200),
+						BeNumerically("<", 300),
+					),
+					Equal(http.StatusMethodNotAllowed),
+				))
+				for _, blob := range []*TestBlob{config, layer} {
+					req := upstreamClient.NewRequest(reggie.DELETE, "/v2/<name>/blobs/<digest>",
+						reggie.WithDigest(blob.Digest))
+					_, err := upstreamClient.Do(req)
+					Expect(err).To(BeNil())
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,252 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+const (
+	defaultBenchmarkIterations  = 20
+	defaultBenchmarkConcurrency = 4
+	defaultBenchmarkBlobSizes   = "1KiB,1MiB"
+	defaultBenchmarkChunkSize   = 1 << 20
+)
+
+type (
+	// benchmarkResult holds the latency and throughput measured for a single
+	// endpoint, and blob size where relevant.
+	benchmarkResult struct {
+		Endpoint        string  `json:"endpoint"`
+		BlobSize        int64   `json:"blobSize,omitempty"`
+		Iterations      int     `json:"iterations"`
+		Concurrency     int     `json:"concurrency"`
+		Errors          int     `json:"errors"`
+		P50Milliseconds float64 `json:"p50Milliseconds"`
+		P95Milliseconds float64 `json:"p95Milliseconds"`
+		P99Milliseconds float64 `json:"p99Milliseconds"`
+		MegabytesPerSec float64 `json:"megabytesPerSecond,omitempty"`
+		FirstError      string  `json:"firstError,omitempty"`
+	}
+
+	// benchmarkResults collects results from all benchmark specs.
+	benchmarkResults struct {
+		mu      sync.Mutex
+		Results []*benchmarkResult
+	}
+
+	// BenchmarkReporter writes the benchmark results as JSON once the suite
+	// has finished. Nothing is written if no benchmarks were run.
+	BenchmarkReporter struct {
+		benchmarkReportFilename string
+	}
+
+	// benchmarkFunc performs one iteration against an endpoint and returns
+	// the number of bytes uploaded or downloaded. For upload benchmarks, blob
+	// is new random content which is prepared before the clock starts.
+	benchmarkFunc func(iteration int, blob *TestBlob) (int64, error)
+)
+
+func (r *benchmarkResults) add(result *benchmarkResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Results = append(r.Results, result)
+}
+
+func (r *benchmarkResults) list() []*benchmarkResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*benchmarkResult{}, r.Results...)
+}
+
+// BlobSizeString formats the blob size for display, e.g. "1MiB".
+func (r *benchmarkResult) BlobSizeString() string {
+	if r.BlobSize == 0 {
+		return "-"
+	}
+	return formatSize(r.BlobSize)
+}
+
+// runBenchmark calls fn the configured number of times, spread over the
+// configured number of concurrent workers, and records the results. If
+// freshBlob is set, each iteration is given a new blob of blobSize bytes.
+func runBenchmark(endpoint string, blobSize int64, freshBlob bool, fn benchmarkFunc) *benchmarkResult {
+	result := &benchmarkResult{
+		Endpoint:    endpoint,
+		BlobSize:    blobSize,
+		Iterations:  benchmarkIterations,
+		Concurrency: benchmarkConcurrency,
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var totalBytes int64
+	latencies := make([]time.Duration, 0, benchmarkIterations)
+	iterations := make(chan int)
+
+	start := time.Now()
+	for w := 0; w < benchmarkConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range iterations {
+				var blob *TestBlob
+				if freshBlob {
+					blob = newTestBlob(randomBlob(blobSize))
+				}
+				t := time.Now()
+				n, err := fn(i, blob)
+				elapsed := time.Since(t)
+
+				mu.Lock()
+				if err != nil {
+					if result.Errors == 0 {
+						result.FirstError = err.Error()
+					}
+					result.Errors++
+				} else {
+					latencies = append(latencies, elapsed)
+					totalBytes += n
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < benchmarkIterations; i++ {
+		iterations <- i
+	}
+	close(iterations)
+	wg.Wait()
+	wall := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	result.P50Milliseconds = percentile(latencies, 50)
+	result.P95Milliseconds = percentile(latencies, 95)
+	result.P99Milliseconds = percentile(latencies, 99)
+	if totalBytes > 0 {
+		result.MegabytesPerSec = float64(totalBytes) / 1e6 / wall.Seconds()
+	}
+
+	benchmarks.add(result)
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies, in
+// milliseconds.
+func percentile(sorted []time.Duration, p int) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return float64(sorted[rank-1]) / float64(time.Millisecond)
+}
+
+// parseSizes parses a comma-separated list of sizes such as "512,1KiB,10MiB".
+func parseSizes(list string) ([]int64, error) {
+	var sizes []int64
+	for _, s := range strings.Split(list, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		size, err := parseSize(s)
+		if err != nil {
+			return nil, err
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func parseSize(s string) (int64, error) {
+	number, multiplier := s, int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			number, multiplier = strings.TrimSuffix(s, unit.suffix), unit.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/multiplier {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+func formatSize(size int64) string {
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{{"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if size >= unit.multiplier && size%unit.multiplier == 0 {
+			return fmt.Sprintf("%d%s", size/unit.multiplier, unit.suffix)
+		}
+	}
+	return fmt.Sprintf("%dB", size)
+}
+
+func newBenchmarkReporter(benchmarkReportFilename string) *BenchmarkReporter {
+	return &BenchmarkReporter{benchmarkReportFilename: benchmarkReportFilename}
+}
+
+func (reporter *BenchmarkReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	results := benchmarks.list()
+	if len(results) == 0 {
+		return
+	}
+
+	b := new(bytes.Buffer)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(results); err != nil {
+		reportError(err)
+		return
+	}
+
+	benchmarkReportFilenameAbsPath, err := filepath.Abs(reporter.benchmarkReportFilename)
+	if err != nil {
+		reportError(err)
+		return
+	}
+
+	if err := ioutil.WriteFile(benchmarkReportFilenameAbsPath, b.Bytes(), 0644); err != nil {
+		reportError(err)
+		return
+	}
+
+	fmt.Printf("Benchmark report was created: %s\n", benchmarkReportFilenameAbsPath)
+}
+
+func (reporter *BenchmarkReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (reporter *BenchmarkReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (reporter *BenchmarkReporter) SpecWillRun(specSummary *types.SpecSummary) {
+}
+
+func (reporter *BenchmarkReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+}
+
+func (reporter *BenchmarkReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+}
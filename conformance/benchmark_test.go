@@ -0,0 +1,107 @@
+package conformance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "512", want: 512},
+		{in: "512B", want: 512},
+		{in: "1KiB", want: 1 << 10},
+		{in: "10MiB", want: 10 << 20},
+		{in: "2GiB", want: 2 << 30},
+		{in: "4 KiB", want: 4 << 10},
+		{in: "", wantErr: true},
+		{in: "KiB", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "-1KiB", wantErr: true},
+		{in: "1.5MiB", wantErr: true},
+		{in: "1KB", wantErr: true},
+		{in: "1kib", wantErr: true},
+		{in: "1TiB", wantErr: true},
+		{in: "10000000000GiB", wantErr: true},
+	} {
+		got, err := parseSize(tc.in)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parseSize(%q) = %d, want an error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestParseSizes(t *testing.T) {
+	sizes, err := parseSizes(" 1KiB, ,512,")
+	if err != nil || len(sizes) != 2 || sizes[0] != 1<<10 || sizes[1] != 512 {
+		t.Errorf("parseSizes = %v, %v", sizes, err)
+	}
+	if _, err := parseSizes("1KiB,1XB"); err == nil {
+		t.Error("parseSizes with an invalid unit did not fail")
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for size, want := range map[int64]string{
+		512:           "512B",
+		1 << 10:       "1KiB",
+		1<<10 + 1:     "1025B",
+		1536 << 10:    "1536KiB",
+		10 << 20:      "10MiB",
+		3 << 30:       "3GiB",
+		4<<30 + 1<<20: "4097MiB",
+	} {
+		if got := formatSize(size); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", size, got, want)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ms := func(ns ...int) []time.Duration {
+		var d []time.Duration
+		for _, n := range ns {
+			d = append(d, time.Duration(n)*time.Millisecond)
+		}
+		return d
+	}
+	hundred := make([]int, 100)
+	for i := range hundred {
+		hundred[i] = i + 1
+	}
+
+	for _, tc := range []struct {
+		name   string
+		sorted []time.Duration
+		p      int
+		want   float64
+	}{
+		{"empty", nil, 50, 0},
+		{"empty p100", nil, 100, 0},
+		{"single", ms(7), 50, 7},
+		{"single p0", ms(7), 0, 7},
+		{"single p100", ms(7), 100, 7},
+		{"p0", ms(1, 2, 3), 0, 1},
+		{"p50 odd", ms(1, 2, 3), 50, 2},
+		{"p50 even", ms(1, 2, 3, 4), 50, 2},
+		{"p99 of 3", ms(1, 2, 3), 99, 3},
+		{"p100", ms(1, 2, 3), 100, 3},
+		{"above p100", ms(1, 2, 3), 150, 3},
+		{"p95 of 100", ms(hundred...), 95, 95},
+		{"p99 of 100", ms(hundred...), 99, 99},
+		{"p100 of 100", ms(hundred...), 100, 100},
+	} {
+		if got := percentile(tc.sorted, tc.p); got != tc.want {
+			t.Errorf("%s: percentile(%v, %d) = %v, want %v", tc.name, tc.sorted, tc.p, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package conformance
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	specs "github.com/opencontainers/distribution-spec/specs-go"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+const (
+	bundleUnsigned bundleSignatureStatus = iota
+	// bundleSignatureUnverified is a signed bundle whose signature was not
+	// checked, as there was no trusted key to check it against
+	bundleSignatureUnverified
+	bundleSignatureVerified
+)
+
+const (
+	bundleManifestName  = "manifest.json"
+	bundleSignatureName = "manifest.json.sig"
+	bundlePublicKeyName = "signing-key.pub"
+)
+
+type (
+	bundleSignatureStatus int
+
+	// bundleManifest describes a certification bundle: which suite and
+	// specification versions produced it, how the suite was configured, and
+	// the digest of every other file in the bundle.
+	bundleManifest struct {
+		SuiteVersion string         `json:"suiteVersion"`
+		SpecVersion  string         `json:"specVersion"`
+		Created      string         `json:"created"`
+		Environment  []string       `json:"environment"`
+		Summary      map[string]int `json:"summary"`
+		Files        []bundleFile   `json:"files"`
+	}
+
+	bundleFile struct {
+		Name   string `json:"name"`
+		Digest string `json:"digest"`
+		Size   int64  `json:"size"`
+	}
+
+	// BundleReporter packages the reports of a run into a single archive. It
+	// must run after the reporters whose files it packages.
+	BundleReporter struct {
+		bundleFilename string
+		signingKeyFile string
+		results        *ResultsReporter
+		environment    []string
+	}
+)
+
+func newBundleReporter(bundleFilename, signingKeyFile string, results *ResultsReporter) *BundleReporter {
+	return &BundleReporter{bundleFilename: bundleFilename, signingKeyFile: signingKeyFile, results: results}
+}
+
+func (reporter *BundleReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+	reporter.environment = runEnvironment
+}
+
+func (reporter *BundleReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	manifest := bundleManifest{
+		SuiteVersion: Version,
+		SpecVersion:  specs.Version,
+		Created:      time.Now().UTC().Format(time.RFC3339),
+		Environment:  reporter.environment,
+		Summary:      map[string]int{},
+	}
+	for _, r := range reporter.results.results.Specs {
+		manifest.Summary[r.Status]++
+	}
+
+	names := []string{reportJUnitFilename, reportHTMLFilename, reportMarkdownFilename, reportResultsFilename}
+	if len(benchmarks.list()) > 0 {
+		// otherwise the file may be left over from an earlier run
+		names = append(names, reportBenchmarkFilename)
+	}
+	files := map[string][]byte{}
+	for _, name := range names {
+		content, err := ioutil.ReadFile(name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			reportError(err)
+			return
+		}
+		name = filepath.Base(name)
+		files[name] = content
+		manifest.Files = append(manifest.Files, bundleFile{
+			Name:   name,
+			Digest: godigest.FromBytes(content).String(),
+			Size:   int64(len(content)),
+		})
+	}
+
+	content, err := json.MarshalIndent(&manifest, "", "  ")
+	if err != nil {
+		reportError(err)
+		return
+	}
+	files[bundleManifestName] = content
+
+	if reporter.signingKeyFile != "" {
+		signature, publicKey, err := signBundleManifest(content, reporter.signingKeyFile)
+		if err != nil {
+			reportError(fmt.Errorf("signing bundle: %v", err))
+			return
+		}
+		files[bundleSignatureName] = signature
+		files[bundlePublicKeyName] = publicKey
+	}
+
+	bundleFilenameAbsPath, err := filepath.Abs(reporter.bundleFilename)
+	if err != nil {
+		reportError(err)
+		return
+	}
+	if err := writeBundle(bundleFilenameAbsPath, files); err != nil {
+		reportError(err)
+		return
+	}
+
+	fmt.Printf("Certification bundle was created: %s\n", bundleFilenameAbsPath)
+}
+
+func (reporter *BundleReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (reporter *BundleReporter) SpecWillRun(specSummary *types.SpecSummary) {
+}
+
+func (reporter *BundleReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+}
+
+func (reporter *BundleReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+// writeBundle writes files to a gzipped tar archive, the manifest first.
+func writeBundle(filename string, files map[string][]byte) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	var reports []string
+	for name := range files {
+		if name != bundleManifestName && name != bundleSignatureName && name != bundlePublicKeyName {
+			reports = append(reports, name)
+		}
+	}
+	sort.Strings(reports)
+	for _, name := range append([]string{bundleManifestName, bundleSignatureName, bundlePublicKeyName}, reports...) {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readBundle returns the files in a bundle.
+func readBundle(filename string) (map[string][]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if files[hdr.Name], err = ioutil.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// verifyBundle checks that every file in a bundle matches the digest in its
+// manifest, and that the manifest is signed by the key in publicKeyFile. The
+// key included in the bundle is not trusted: without publicKeyFile, the
+// signature of a signed bundle is not checked, and the status returned says
+// so.
+func verifyBundle(filename, publicKeyFile string) (*bundleManifest, bundleSignatureStatus, error) {
+	files, err := readBundle(filename)
+	if err != nil {
+		return nil, bundleUnsigned, err
+	}
+	content, ok := files[bundleManifestName]
+	if !ok {
+		return nil, bundleUnsigned, fmt.Errorf("no %s", bundleManifestName)
+	}
+	var manifest bundleManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, bundleUnsigned, fmt.Errorf("%s: %v", bundleManifestName, err)
+	}
+
+	listed := map[string]bool{bundleManifestName: true, bundleSignatureName: true, bundlePublicKeyName: true}
+	for _, file := range manifest.Files {
+		listed[file.Name] = true
+		b, ok := files[file.Name]
+		if !ok {
+			return nil, bundleUnsigned, fmt.Errorf("%s is missing", file.Name)
+		}
+		if int64(len(b)) != file.Size || godigest.FromBytes(b).String() != file.Digest {
+			return nil, bundleUnsigned, fmt.Errorf("%s does not match its digest", file.Name)
+		}
+	}
+	for name := range files {
+		if !listed[name] {
+			return nil, bundleUnsigned, fmt.Errorf("%s is not listed in %s", name, bundleManifestName)
+		}
+	}
+
+	signature, signed := files[bundleSignatureName]
+	switch {
+	case !signed && publicKeyFile != "":
+		return nil, bundleUnsigned, errors.New("bundle is not signed")
+	case !signed:
+		return &manifest, bundleUnsigned, nil
+	case publicKeyFile == "":
+		return &manifest, bundleSignatureUnverified, nil
+	}
+	publicKey, err := ioutil.ReadFile(publicKeyFile)
+	if err != nil {
+		return nil, bundleSignatureUnverified, err
+	}
+	if err := verifyBundleManifest(content, signature, publicKey); err != nil {
+		return nil, bundleSignatureUnverified, err
+	}
+	return &manifest, bundleSignatureVerified, nil
+}
+
+// readSigningKey reads the PEM-encoded Ed25519, ECDSA or RSA private key in
+// keyFile.
+func readSigningKey(keyFile string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", keyFile)
+	}
+	var key interface{}
+	if key, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+		if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+			if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("%s: unsupported private key", keyFile)
+			}
+		}
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key", keyFile)
+	}
+	return signer, nil
+}
+
+// signBundleManifest signs the manifest with the PEM-encoded Ed25519, ECDSA
+// or RSA private key in keyFile, and returns the base64-encoded signature
+// and the PEM-encoded public key.
+func signBundleManifest(manifest []byte, keyFile string) ([]byte, []byte, error) {
+	signer, err := readSigningKey(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var signature []byte
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		signature, err = signer.Sign(rand.Reader, manifest, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(manifest)
+		signature, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return []byte(base64.StdEncoding.EncodeToString(signature)), publicKey, nil
+}
+
+func verifyBundleManifest(manifest, signature, publicKey []byte) error {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return errors.New("no PEM public key found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil {
+		return fmt.Errorf("%s: %v", bundleSignatureName, err)
+	}
+
+	digest := sha256.Sum256(manifest)
+	valid := false
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, manifest, sig)
+	case *ecdsa.PublicKey:
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &rs); err == nil {
+			valid = ecdsa.Verify(key, digest[:], rs.R, rs.S)
+		}
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return errors.New("unsupported public key")
+	}
+	if !valid {
+		return fmt.Errorf("%s does not match %s", bundleSignatureName, bundleManifestName)
+	}
+	return nil
+}
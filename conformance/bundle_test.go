@@ -0,0 +1,310 @@
+package conformance
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/onsi/ginkgo/config"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+// writeKeys writes a new private key of the given type, PEM-encoded the way
+// the usual tools do, and returns its file and that of its public key.
+func writeKeys(t *testing.T, keyType string) (string, string) {
+	var block *pem.Block
+	switch keyType {
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	case "ecdsa":
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	case "rsa":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	}
+
+	dir := t.TempDir()
+	keyFile, publicKeyFile := filepath.Join(dir, "key.pem"), filepath.Join(dir, "key.pub")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, publicKey, err := signBundleManifest(nil, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicKeyFile, publicKey, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return keyFile, publicKeyFile
+}
+
+// makeBundle writes a bundle of reports, signed with keyFile unless it is
+// empty, the way the bundle reporter does.
+func makeBundle(t *testing.T, reports map[string][]byte, keyFile string) string {
+	manifest := bundleManifest{SuiteVersion: Version, Summary: map[string]int{statusPassed: 1}}
+	files := map[string][]byte{}
+	for name, content := range reports {
+		files[name] = content
+		manifest.Files = append(manifest.Files, bundleFile{
+			Name:   name,
+			Digest: godigest.FromBytes(content).String(),
+			Size:   int64(len(content)),
+		})
+	}
+	content, err := json.Marshal(&manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files[bundleManifestName] = content
+	if keyFile != "" {
+		if files[bundleSignatureName], files[bundlePublicKeyName], err = signBundleManifest(content, keyFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := writeBundle(filename, files); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// rewriteBundle changes the files of a bundle in place.
+func rewriteBundle(t *testing.T, filename string, change func(files map[string][]byte)) {
+	files, err := readBundle(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change(files)
+	if err := writeBundle(filename, files); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBundleSignatures(t *testing.T) {
+	reports := map[string][]byte{"junit.xml": []byte("<testsuite></testsuite>"), "results.json": []byte("{}")}
+
+	for _, keyType := range []string{"ed25519", "ecdsa", "rsa"} {
+		t.Run(keyType, func(t *testing.T) {
+			keyFile, publicKeyFile := writeKeys(t, keyType)
+			_, otherPublicKeyFile := writeKeys(t, keyType)
+			filename := makeBundle(t, reports, keyFile)
+
+			manifest, status, err := verifyBundle(filename, publicKeyFile)
+			if err != nil || status != bundleSignatureVerified {
+				t.Fatalf("with the signing key: %v, %v", status, err)
+			}
+			if len(manifest.Files) != len(reports) {
+				t.Errorf("%d files in the manifest, want %d", len(manifest.Files), len(reports))
+			}
+
+			// the key in the bundle is not enough
+			if _, status, err := verifyBundle(filename, ""); err != nil || status != bundleSignatureUnverified {
+				t.Errorf("without a key: %v, %v", status, err)
+			}
+			if _, status, err := verifyBundle(filename, otherPublicKeyFile); err == nil || status == bundleSignatureVerified {
+				t.Errorf("with another key: %v, %v", status, err)
+			}
+		})
+	}
+}
+
+func TestBundleTampering(t *testing.T) {
+	reports := map[string][]byte{"junit.xml": []byte("<testsuite></testsuite>"), "results.json": []byte("{}")}
+	keyFile, publicKeyFile := writeKeys(t, "ed25519")
+	otherKeyFile, _ := writeKeys(t, "ecdsa")
+
+	for _, tc := range []struct {
+		name   string
+		change func(files map[string][]byte)
+	}{
+		{"tampered file", func(files map[string][]byte) {
+			files["results.json"] = []byte(`{"specs": []}`)
+		}},
+		{"extra file", func(files map[string][]byte) {
+			files["extra.txt"] = []byte("not listed")
+		}},
+		{"missing file", func(files map[string][]byte) {
+			delete(files, "junit.xml")
+		}},
+		{"tampered manifest", func(files map[string][]byte) {
+			var manifest bundleManifest
+			if err := json.Unmarshal(files[bundleManifestName], &manifest); err != nil {
+				t.Fatal(err)
+			}
+			manifest.Summary[statusPassed] = 100
+			files[bundleManifestName], _ = json.Marshal(&manifest)
+		}},
+		{"resigned with another key", func(files map[string][]byte) {
+			var err error
+			files[bundleSignatureName], files[bundlePublicKeyName], err = signBundleManifest(files[bundleManifestName], otherKeyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"signature removed", func(files map[string][]byte) {
+			delete(files, bundleSignatureName)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filename := makeBundle(t, reports, keyFile)
+			rewriteBundle(t, filename, tc.change)
+			if _, status, err := verifyBundle(filename, publicKeyFile); err == nil {
+				t.Errorf("verified with status %v", status)
+			}
+		})
+	}
+
+	// a bundle resigned by someone else still looks signed, so without the
+	// trusted key it must not count as verified
+	filename := makeBundle(t, reports, keyFile)
+	rewriteBundle(t, filename, func(files map[string][]byte) {
+		var err error
+		files[bundleSignatureName], files[bundlePublicKeyName], err = signBundleManifest(files[bundleManifestName], otherKeyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	if _, status, err := verifyBundle(filename, ""); err != nil || status != bundleSignatureUnverified {
+		t.Errorf("resigned bundle without a key: %v, %v", status, err)
+	}
+}
+
+func TestUnsignedBundle(t *testing.T) {
+	_, publicKeyFile := writeKeys(t, "ed25519")
+	filename := makeBundle(t, map[string][]byte{"results.json": []byte("{}")}, "")
+
+	if _, status, err := verifyBundle(filename, ""); err != nil || status != bundleUnsigned {
+		t.Errorf("without a key: %v, %v", status, err)
+	}
+	if _, _, err := verifyBundle(filename, publicKeyFile); err == nil {
+		t.Error("an unsigned bundle was verified with a key")
+	}
+}
+
+// The bundle records the version of the specification it was built against,
+// which is the one in this repository.
+func TestBundleReporter(t *testing.T) {
+	skipIfConformanceRun(t)
+	dir := t.TempDir()
+	saved := []string{reportJUnitFilename, reportHTMLFilename, reportMarkdownFilename, reportResultsFilename, reportBenchmarkFilename}
+	savedBenchmarks := benchmarks
+	t.Cleanup(func() {
+		reportJUnitFilename, reportHTMLFilename, reportMarkdownFilename, reportResultsFilename, reportBenchmarkFilename =
+			saved[0], saved[1], saved[2], saved[3], saved[4]
+		benchmarks = savedBenchmarks
+	})
+	benchmarks = &benchmarkResults{}
+	reportJUnitFilename = filepath.Join(dir, "junit.xml")
+	reportHTMLFilename = filepath.Join(dir, "report.html")
+	reportMarkdownFilename = filepath.Join(dir, "report.md")
+	reportResultsFilename = filepath.Join(dir, "results.json")
+	reportBenchmarkFilename = filepath.Join(dir, "benchmark.json")
+	for _, name := range []string{reportJUnitFilename, reportResultsFilename} {
+		if err := os.WriteFile(name, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := newResultsReporter(reportResultsFilename)
+	results.results.Specs = []SpecResult{{Name: "a", Status: statusPassed}, {Name: "b", Status: statusFailed}}
+	keyFile, publicKeyFile := writeKeys(t, "ecdsa")
+	filename := filepath.Join(dir, "bundle.tar.gz")
+	reporter := newBundleReporter(filename, keyFile, results)
+	reporter.SpecSuiteWillBegin(config.GinkgoConfig, nil)
+	reporter.SpecSuiteDidEnd(nil)
+
+	manifest, status, err := verifyBundle(filename, publicKeyFile)
+	if err != nil || status != bundleSignatureVerified {
+		t.Fatalf("%v, %v", status, err)
+	}
+	if manifest.SpecVersion != "1.0.0-dev" || manifest.SuiteVersion != Version {
+		t.Errorf("spec version %q, suite version %q", manifest.SpecVersion, manifest.SuiteVersion)
+	}
+	if len(manifest.Files) != 2 || manifest.Summary[statusPassed] != 1 || manifest.Summary[statusFailed] != 1 {
+		t.Errorf("files %v, summary %v", manifest.Files, manifest.Summary)
+	}
+}
+
+// The bundle packages the HTML report, which embeds the log of the requests
+// of the run, so the credentials of the run must have been removed from it.
+func TestBundleRedaction(t *testing.T) {
+	skipIfConformanceRun(t)
+	reg := newFakeRegistry()
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token": "token-secret"}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token-secret" {
+			w.Header().Set("Www-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	_, err := Run(context.Background(), Config{
+		RootURL:   srv.URL,
+		Namespace: "conformance/test",
+		Username:  "user",
+		Password:  "password-secret",
+		Workflows: WorkflowPull,
+		ReportDir: dir,
+		Transport: srv.Client().Transport,
+		Bundle:    filepath.Join(dir, "bundle.tar.gz"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := readBundle(filepath.Join(dir, "bundle.tar.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := files["report.html"]; !ok {
+		t.Fatal("the bundle has no HTML report")
+	}
+	for name, content := range files {
+		for _, secret := range []string{"password-secret", "dXNlcjpwYXNzd29yZC1zZWNyZXQ=", "token-secret"} {
+			if strings.Contains(string(content), secret) {
+				t.Errorf("%s contains %q", name, secret)
+			}
+		}
+	}
+}
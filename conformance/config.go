@@ -0,0 +1,311 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Workflow selects workflows to run; workflows are combined with |.
+type Workflow int
+
+const (
+	WorkflowPull              Workflow = pull
+	WorkflowPush              Workflow = push
+	WorkflowContentDiscovery  Workflow = contentDiscovery
+	WorkflowContentManagement Workflow = contentManagement
+	WorkflowBenchmark         Workflow = benchmark
+	WorkflowTagMutability     Workflow = tagMutability
+	WorkflowArtifacts         Workflow = artifacts
+	WorkflowReferrers         Workflow = referrers
+	WorkflowDockerSchema2     Workflow = dockerSchema2
+	WorkflowMirror            Workflow = mirror
+)
+
+// Config configures a run of the suite. Each field corresponds to one of the
+// environment variables described in the README, which ConfigFromEnv reads;
+// zero values select the same defaults as an unset variable.
+type Config struct {
+	// RootURL is the URL of the registry under test. To test an
+	// http.Handler in-process, use the URL of an httptest.Server.
+	RootURL   string
+	Namespace string
+	Username  string
+	Password  string
+	AuthScope string
+	Debug     bool
+
+	// Workflows to run, e.g. WorkflowPull | WorkflowPush.
+	Workflows Workflow
+
+	CrossmountNamespace           string
+	CrossmountUnreadableNamespace string
+	// CrossmountUnreadableDigest is a blob which exists in
+	// CrossmountUnreadableNamespace.
+	CrossmountUnreadableDigest string
+
+	// BlobDigest, ManifestDigest and TagName refer to existing content to
+	// pull instead of pushing it first; TagList lists the existing tags of
+	// Namespace for the Content Discovery workflow.
+	BlobDigest     string
+	ManifestDigest string
+	TagName        string
+	TagList        []string
+
+	SkipEmptyLayerPush        bool
+	DeleteManifestBeforeBlobs bool
+	HideSkippedWorkflows      bool
+
+	// IncludeSpecs and ExcludeSpecs are comma-separated spec IDs or
+	// patterns; WaiversFile is the path to a file of expected failures.
+	IncludeSpecs string
+	ExcludeSpecs string
+	WaiversFile  string
+
+	BenchmarkIterations  int
+	BenchmarkConcurrency int
+	BenchmarkBlobSizes   []int64
+	BenchmarkChunkSize   int64
+
+	TLSCAFile   string
+	TLSCertFile string
+	TLSKeyFile  string
+	PlainHTTP   bool
+
+	// Transport, if set, sends the requests to the registry and to the
+	// upstream of a mirror, e.g. the transport of the Client of an
+	// httptest.NewTLSServer. TLS is then configured on the transport
+	// instead of with TLSCAFile, TLSCertFile and TLSKeyFile.
+	Transport http.RoundTripper
+
+	MirrorUpstreamURL       string
+	MirrorUpstreamNamespace string
+	MirrorUpstreamUsername  string
+	MirrorUpstreamPassword  string
+
+	// ReportDir is where the reports are written; the current directory
+	// if empty.
+	ReportDir string
+
+	// Bundle is the path of a certification bundle to write, signed with
+	// the private key in BundleSigningKey if that is set.
+	Bundle           string
+	BundleSigningKey string
+
+	// ResultsNamespace is a repository to push the results to.
+	ResultsNamespace string
+
+	// Isolate runs the workflows in a new repository under Namespace, and
+	// prefixes their tags with an ID of the run, so that concurrent runs
+	// against the same registry do not interfere.
+	Isolate bool
+}
+
+var workflowEnvVars = map[string]Workflow{
+	envVarPull:              WorkflowPull,
+	envVarPush:              WorkflowPush,
+	envVarContentDiscovery:  WorkflowContentDiscovery,
+	envVarContentManagement: WorkflowContentManagement,
+	envVarBenchmark:         WorkflowBenchmark,
+	envVarTagMutability:     WorkflowTagMutability,
+	envVarArtifacts:         WorkflowArtifacts,
+	envVarReferrers:         WorkflowReferrers,
+	envVarDockerSchema2:     WorkflowDockerSchema2,
+	envVarMirror:            WorkflowMirror,
+}
+
+// ConfigFromEnv reads the configuration of a run from the OCI_* environment
+// variables, or the matching GitHub Action inputs.
+func ConfigFromEnv() (Config, error) {
+	var err error
+	cfg := Config{
+		RootURL:                       getEnv(envVarRootURL),
+		Namespace:                     getEnv(envVarNamespace),
+		Username:                      getEnv(envVarUsername),
+		Password:                      getEnv(envVarPassword),
+		AuthScope:                     getEnv(envVarAuthScope),
+		CrossmountNamespace:           getEnv(envVarCrossmountNamespace),
+		CrossmountUnreadableNamespace: getEnv(envVarCrossmountUnreadable),
+		CrossmountUnreadableDigest:    getEnv(envVarCrossmountUnreadableBlob),
+		BlobDigest:                    getEnv(envVarBlobDigest),
+		ManifestDigest:                getEnv(envVarManifestDigest),
+		TagName:                       getEnv(envVarTagName),
+		IncludeSpecs:                  getEnv(envVarIncludeSpecs),
+		ExcludeSpecs:                  getEnv(envVarExcludeSpecs),
+		WaiversFile:                   getEnv(envVarWaiversFile),
+		TLSCAFile:                     getEnv(envVarTLSCAFile),
+		TLSCertFile:                   getEnv(envVarTLSCertFile),
+		TLSKeyFile:                    getEnv(envVarTLSKeyFile),
+		MirrorUpstreamURL:             getEnv(envVarMirrorUpstreamURL),
+		MirrorUpstreamNamespace:       getEnv(envVarMirrorUpstreamNamespace),
+		MirrorUpstreamUsername:        getEnv(envVarMirrorUpstreamUsername),
+		MirrorUpstreamPassword:        getEnv(envVarMirrorUpstreamPassword),
+		Bundle:                        getEnv(envVarBundle),
+		BundleSigningKey:              getEnv(envVarBundleSigningKey),
+		ResultsNamespace:              getEnv(envVarResultsNamespace),
+	}
+	if v := getEnv(envVarTagList); v != "" {
+		cfg.TagList = strings.Split(v, ",")
+	}
+
+	cfg.Debug, _ = strconv.ParseBool(getEnv(envVarDebug))
+	cfg.PlainHTTP, _ = strconv.ParseBool(getEnv(envVarPlainHTTP))
+	cfg.SkipEmptyLayerPush, _ = strconv.ParseBool(getEnv(envVarPushEmptyLayer))
+	cfg.DeleteManifestBeforeBlobs, _ = strconv.ParseBool(getEnv(envVarDeleteManifestBeforeBlobs))
+	cfg.HideSkippedWorkflows = getEnv(envVarHideSkippedWorkflows) == "1"
+	cfg.Isolate, _ = strconv.ParseBool(getEnv(envVarIsolate))
+	for envVar, workflow := range workflowEnvVars {
+		if varIsTrue, _ := strconv.ParseBool(getEnv(envVar)); varIsTrue {
+			cfg.Workflows |= workflow
+		}
+	}
+
+	if v := getEnv(envVarBenchmarkIterations); v != "" {
+		if cfg.BenchmarkIterations, err = strconv.Atoi(v); err != nil || cfg.BenchmarkIterations < 1 {
+			return cfg, fmt.Errorf("invalid %s: %q", envVarBenchmarkIterations, v)
+		}
+	}
+	if v := getEnv(envVarBenchmarkConcurrency); v != "" {
+		if cfg.BenchmarkConcurrency, err = strconv.Atoi(v); err != nil || cfg.BenchmarkConcurrency < 1 {
+			return cfg, fmt.Errorf("invalid %s: %q", envVarBenchmarkConcurrency, v)
+		}
+	}
+	if v := getEnv(envVarBenchmarkBlobSizes); v != "" {
+		if cfg.BenchmarkBlobSizes, err = parseSizes(v); err != nil {
+			return cfg, fmt.Errorf("invalid %s: %v", envVarBenchmarkBlobSizes, err)
+		}
+	}
+	if v := getEnv(envVarBenchmarkChunkSize); v != "" {
+		if cfg.BenchmarkChunkSize, err = parseSize(v); err != nil {
+			return cfg, fmt.Errorf("invalid %s: %v", envVarBenchmarkChunkSize, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// environment lists the environment variables which select the
+// configuration, with usernames and passwords redacted, for the reports of
+// a run.
+func (cfg *Config) environment() []string {
+	var environment []string
+	add := func(envVar, value string) {
+		if value != "" {
+			environment = append(environment, envVar+"="+value)
+		}
+	}
+	redacted := func(v string) string {
+		if v == "" {
+			return ""
+		}
+		return "*****"
+	}
+	flag := func(set bool) string {
+		if !set {
+			return ""
+		}
+		return "1"
+	}
+	number := func(n int64, format func(int64) string) string {
+		if n == 0 {
+			return ""
+		}
+		return format(n)
+	}
+	itoa := func(n int64) string { return strconv.FormatInt(n, 10) }
+	var blobSizes []string
+	for _, size := range cfg.BenchmarkBlobSizes {
+		blobSizes = append(blobSizes, formatSize(size))
+	}
+
+	add(envVarRootURL, cfg.RootURL)
+	add(envVarNamespace, cfg.Namespace)
+	add(envVarUsername, redacted(cfg.Username))
+	add(envVarPassword, redacted(cfg.Password))
+	add(envVarDebug, flag(cfg.Debug))
+	add(envVarPull, flag(cfg.Workflows&WorkflowPull != 0))
+	add(envVarPush, flag(cfg.Workflows&WorkflowPush != 0))
+	add(envVarContentDiscovery, flag(cfg.Workflows&WorkflowContentDiscovery != 0))
+	add(envVarContentManagement, flag(cfg.Workflows&WorkflowContentManagement != 0))
+	add(envVarPushEmptyLayer, flag(cfg.SkipEmptyLayerPush))
+	add(envVarBlobDigest, cfg.BlobDigest)
+	add(envVarManifestDigest, cfg.ManifestDigest)
+	add(envVarTagName, cfg.TagName)
+	add(envVarTagList, strings.Join(cfg.TagList, ","))
+	add(envVarHideSkippedWorkflows, flag(cfg.HideSkippedWorkflows))
+	add(envVarAuthScope, cfg.AuthScope)
+	add(envVarDeleteManifestBeforeBlobs, flag(cfg.DeleteManifestBeforeBlobs))
+	add(envVarCrossmountNamespace, cfg.CrossmountNamespace)
+	add(envVarCrossmountUnreadable, cfg.CrossmountUnreadableNamespace)
+	add(envVarCrossmountUnreadableBlob, cfg.CrossmountUnreadableDigest)
+	add(envVarIncludeSpecs, cfg.IncludeSpecs)
+	add(envVarExcludeSpecs, cfg.ExcludeSpecs)
+	add(envVarWaiversFile, cfg.WaiversFile)
+	add(envVarBenchmark, flag(cfg.Workflows&WorkflowBenchmark != 0))
+	add(envVarBenchmarkIterations, number(int64(cfg.BenchmarkIterations), itoa))
+	add(envVarBenchmarkConcurrency, number(int64(cfg.BenchmarkConcurrency), itoa))
+	add(envVarBenchmarkBlobSizes, strings.Join(blobSizes, ","))
+	add(envVarBenchmarkChunkSize, number(cfg.BenchmarkChunkSize, formatSize))
+	add(envVarTagMutability, flag(cfg.Workflows&WorkflowTagMutability != 0))
+	add(envVarArtifacts, flag(cfg.Workflows&WorkflowArtifacts != 0))
+	add(envVarReferrers, flag(cfg.Workflows&WorkflowReferrers != 0))
+	add(envVarDockerSchema2, flag(cfg.Workflows&WorkflowDockerSchema2 != 0))
+	add(envVarTLSCAFile, cfg.TLSCAFile)
+	add(envVarTLSCertFile, cfg.TLSCertFile)
+	add(envVarTLSKeyFile, cfg.TLSKeyFile)
+	add(envVarPlainHTTP, flag(cfg.PlainHTTP))
+	add(envVarMirror, flag(cfg.Workflows&WorkflowMirror != 0))
+	add(envVarMirrorUpstreamURL, cfg.MirrorUpstreamURL)
+	add(envVarMirrorUpstreamNamespace, cfg.MirrorUpstreamNamespace)
+	add(envVarMirrorUpstreamUsername, redacted(cfg.MirrorUpstreamUsername))
+	add(envVarMirrorUpstreamPassword, redacted(cfg.MirrorUpstreamPassword))
+	add(envVarBundle, cfg.Bundle)
+	add(envVarBundleSigningKey, cfg.BundleSigningKey)
+	add(envVarResultsNamespace, cfg.ResultsNamespace)
+	add(envVarIsolate, flag(cfg.Isolate))
+	return environment
+}
+
+// validate checks the configuration before anything is sent to the
+// registry.
+func (cfg *Config) validate() error {
+	// without workflows, nothing is sent to the registry, but the
+	// reports are still written
+	if cfg.Workflows != 0 || cfg.ResultsNamespace != "" {
+		if cfg.RootURL == "" {
+			return fmt.Errorf("%s must be set", envVarRootURL)
+		}
+		if cfg.Namespace == "" {
+			return fmt.Errorf("%s must be set", envVarNamespace)
+		}
+	}
+	if cfg.Workflows&WorkflowMirror != 0 && cfg.MirrorUpstreamURL == "" {
+		return fmt.Errorf("%s must be set to test a mirror", envVarMirrorUpstreamURL)
+	}
+	if cfg.BenchmarkIterations < 0 || cfg.BenchmarkConcurrency < 0 || cfg.BenchmarkChunkSize < 0 {
+		return fmt.Errorf("benchmark settings must not be negative")
+	}
+	for _, size := range cfg.BenchmarkBlobSizes {
+		if size <= 0 {
+			return fmt.Errorf("invalid benchmark blob size %d", size)
+		}
+	}
+	if cfg.Isolate && (cfg.TagName != "" || len(cfg.TagList) > 0) {
+		return fmt.Errorf("%s cannot be used with existing content (%s, %s)", envVarIsolate, envVarTagName, envVarTagList)
+	}
+	if cfg.Transport != nil && (cfg.TLSCAFile != "" || cfg.TLSCertFile != "" || cfg.TLSKeyFile != "") {
+		return fmt.Errorf("%s, %s and %s cannot be used with a Transport; configure TLS on the Transport instead",
+			envVarTLSCAFile, envVarTLSCertFile, envVarTLSKeyFile)
+	}
+	if cfg.BundleSigningKey != "" && cfg.Bundle == "" {
+		return fmt.Errorf("%s requires %s", envVarBundleSigningKey, envVarBundle)
+	}
+	// otherwise the run would only fail to sign the bundle once it is over
+	if cfg.BundleSigningKey != "" {
+		if _, err := readSigningKey(cfg.BundleSigningKey); err != nil {
+			return fmt.Errorf("invalid %s: %v", envVarBundleSigningKey, err)
+		}
+	}
+	return nil
+}
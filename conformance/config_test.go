@@ -0,0 +1,44 @@
+package conformance
+
+import (
+	"reflect"
+	"testing"
+)
+
+// The reports describe the configuration of a run, not the environment of
+// the process, which a library run does not read.
+func TestConfigEnvironment(t *testing.T) {
+	t.Setenv(envVarNamespace, "from/environment")
+	t.Setenv(envVarPassword, "environment-secret")
+	cfg := Config{
+		RootURL:                "https://registry.example.com",
+		Namespace:              "myorg/myrepo",
+		Username:               "user",
+		Password:               "secret",
+		Workflows:              WorkflowPull | WorkflowBenchmark | WorkflowMirror,
+		TagList:                []string{"a", "b"},
+		BenchmarkIterations:    5,
+		BenchmarkBlobSizes:     []int64{1 << 10, 10 << 20},
+		MirrorUpstreamURL:      "https://upstream.example.com",
+		MirrorUpstreamPassword: "upstream-secret",
+		Isolate:                true,
+	}
+	want := []string{
+		"OCI_ROOT_URL=https://registry.example.com",
+		"OCI_NAMESPACE=myorg/myrepo",
+		"OCI_USERNAME=*****",
+		"OCI_PASSWORD=*****",
+		"OCI_TEST_PULL=1",
+		"OCI_TAG_LIST=a,b",
+		"OCI_TEST_BENCHMARK=1",
+		"OCI_BENCHMARK_ITERATIONS=5",
+		"OCI_BENCHMARK_BLOB_SIZES=1KiB,10MiB",
+		"OCI_TEST_MIRROR=1",
+		"OCI_MIRROR_UPSTREAM_URL=https://upstream.example.com",
+		"OCI_MIRROR_UPSTREAM_PASSWORD=*****",
+		"OCI_ISOLATE=1",
+	}
+	if got := cfg.environment(); !reflect.DeepEqual(got, want) {
+		t.Errorf("environment() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package conformance
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bloodorangeio/reggie"
+	g "github.com/onsi/ginkgo"
+)
+
+const (
+	blockedPrefix = "blocked by "
+)
+
+type (
+	// dependency is a spec which later specs depend on, e.g. the spec which
+	// opens an upload session that the next spec completes. It hands on the
+	// response it ended with, but only if it passed.
+	dependency struct {
+		title    string
+		ran      bool
+		passed   bool
+		response *reggie.Response
+	}
+)
+
+// specifyDependency declares a spec like g.Specify, whose body returns the
+// response that the specs requiring it continue from.
+func specifyDependency(title string, body func() *reggie.Response) *dependency {
+	d := &dependency{title: title}
+	g.Specify(title, func() {
+		d.ran, d.passed, d.response = true, false, nil
+		resp := body()
+		// a failed expectation or a skip does not return here
+		d.passed, d.response = true, resp
+	})
+	return d
+}
+
+// requires skips the current spec as blocked unless the spec it depends on
+// passed, and returns the response of that spec.
+func requires(d *dependency) *reggie.Response {
+	if !d.passed {
+		if !d.ran {
+			g.Skip(fmt.Sprintf("%s%q, which has not run", blockedPrefix, d.title))
+		}
+		g.Skip(fmt.Sprintf("%s%q", blockedPrefix, d.title))
+	}
+	return d.response
+}
+
+// requiresIf is requires, but only if run is set, e.g. when the setup spec
+// depended on runs rather than existing content being used.
+func requiresIf(run bool, d *dependency) *reggie.Response {
+	if !run {
+		return nil
+	}
+	return requires(d)
+}
+
+func isBlocked(message string) bool {
+	return strings.HasPrefix(message, blockedPrefix)
+}
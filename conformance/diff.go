@@ -0,0 +1,142 @@
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDiffTimingThreshold = 50
+
+	// diffTimingFloor is how much slower a spec must be for it to count as
+	// a timing regression, whatever the threshold, so that specs taking a
+	// few milliseconds do not regress on noise alone
+	diffTimingFloor = 100 * time.Millisecond
+)
+
+type (
+	// resultsDiff is the difference between a baseline run and a later run.
+	resultsDiff struct {
+		Changed     []statusChange
+		NewFailures []SpecResult
+		Slower      []timingChange
+		Threshold   int
+	}
+
+	// statusChange is a spec whose status differs between the runs. A spec
+	// only present in one run has an empty status in the other.
+	statusChange struct {
+		Name   string
+		Before string
+		After  string
+	}
+
+	timingChange struct {
+		Name   string
+		Before time.Duration
+		After  time.Duration
+	}
+)
+
+// diffResults compares the results of two runs. Specs are matched by spec
+// ID, so that a title which only changed in case or punctuation is still the
+// same spec, and by name where either run has no IDs, as in JUnit reports. A
+// spec regressed if it failed in results but not in baseline, or if it took
+// threshold percent longer.
+func diffResults(baseline, results []SpecResult, threshold int) *resultsDiff {
+	d := &resultsDiff{Threshold: threshold}
+
+	byID, byName := map[string]int{}, map[string]int{}
+	for i, r := range baseline {
+		if r.ID != "" {
+			byID[r.ID] = i
+		}
+		byName[r.Name] = i
+	}
+	matched := map[int]bool{}
+
+	for _, r := range results {
+		i, ok := byID[r.ID]
+		if !ok || r.ID == "" {
+			i, ok = byName[r.Name]
+		}
+		ok = ok && !matched[i]
+		var b SpecResult
+		if ok {
+			matched[i], b = true, baseline[i]
+		}
+
+		if !ok {
+			d.Changed = append(d.Changed, statusChange{Name: r.Name, After: r.Status})
+		} else if b.Status != r.Status {
+			d.Changed = append(d.Changed, statusChange{Name: r.Name, Before: b.Status, After: r.Status})
+		}
+		if r.Status == statusFailed && (!ok || b.Status != statusFailed) {
+			d.NewFailures = append(d.NewFailures, r)
+		}
+
+		// only specs which ran to completion both times are comparable
+		if !ok || b.Status != statusPassed || r.Status != statusPassed {
+			continue
+		}
+		t := timingChange{
+			Name:   r.Name,
+			Before: time.Duration(b.Seconds * float64(time.Second)),
+			After:  time.Duration(r.Seconds * float64(time.Second)),
+		}
+		if t.After-t.Before >= diffTimingFloor && t.After > t.Before+t.Before*time.Duration(threshold)/100 {
+			d.Slower = append(d.Slower, t)
+		}
+	}
+
+	for i, b := range baseline {
+		if !matched[i] {
+			d.Changed = append(d.Changed, statusChange{Name: b.Name, Before: b.Status})
+		}
+	}
+	return d
+}
+
+// Regressions returns the number of new failures and timing regressions.
+func (d *resultsDiff) Regressions() int {
+	return len(d.NewFailures) + len(d.Slower)
+}
+
+func (d *resultsDiff) print(w io.Writer) {
+	fmt.Fprintf(w, "Status changes: %d\n", len(d.Changed))
+	for _, c := range d.Changed {
+		fmt.Fprintf(w, "  %-8s -> %-8s %s\n", orNone(c.Before), orNone(c.After), c.Name)
+	}
+
+	fmt.Fprintf(w, "\nNew failures: %d\n", len(d.NewFailures))
+	for _, r := range d.NewFailures {
+		fmt.Fprintf(w, "  %s\n", r.Name)
+		for _, line := range strings.Split(strings.TrimSpace(r.Message), "\n") {
+			fmt.Fprintf(w, "      %s\n", line)
+		}
+	}
+
+	fmt.Fprintf(w, "\nTiming regressions (more than %d%% and %s slower): %d\n", d.Threshold, diffTimingFloor, len(d.Slower))
+	for _, t := range d.Slower {
+		fmt.Fprintf(w, "  %s -> %s (%s) %s\n", t.Before.Round(time.Millisecond), t.After.Round(time.Millisecond),
+			increase(t.Before, t.After), t.Name)
+	}
+
+	fmt.Fprintf(w, "\n%d regressions\n", d.Regressions())
+}
+
+func orNone(status string) string {
+	if status == "" {
+		return "(none)"
+	}
+	return status
+}
+
+func increase(before, after time.Duration) string {
+	if before <= 0 {
+		return "+" + (after - before).Round(time.Millisecond).String()
+	}
+	return fmt.Sprintf("+%d%%", (after-before)*100/before)
+}
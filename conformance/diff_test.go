@@ -0,0 +1,199 @@
+package conformance
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	spec := func(id, name, status string, seconds float64) SpecResult {
+		return SpecResult{ID: id, Name: name, Status: status, Seconds: seconds}
+	}
+
+	for _, tc := range []struct {
+		name        string
+		baseline    []SpecResult
+		results     []SpecResult
+		threshold   int
+		changed     []statusChange
+		newFailures []string
+		slower      []string
+	}{
+		{
+			name:     "unchanged",
+			baseline: []SpecResult{spec("a", "A", statusPassed, 1), spec("b", "B", statusFailed, 1)},
+			results:  []SpecResult{spec("a", "A", statusPassed, 1), spec("b", "B", statusFailed, 1)},
+		},
+		{
+			name:        "regression",
+			baseline:    []SpecResult{spec("a", "A", statusPassed, 1)},
+			results:     []SpecResult{spec("a", "A", statusFailed, 1)},
+			changed:     []statusChange{{"A", statusPassed, statusFailed}},
+			newFailures: []string{"A"},
+		},
+		{
+			name:        "skipped spec fails",
+			baseline:    []SpecResult{spec("a", "A", statusSkipped, 0)},
+			results:     []SpecResult{spec("a", "A", statusFailed, 1)},
+			changed:     []statusChange{{"A", statusSkipped, statusFailed}},
+			newFailures: []string{"A"},
+		},
+		{
+			name:     "fix",
+			baseline: []SpecResult{spec("a", "A", statusFailed, 1)},
+			results:  []SpecResult{spec("a", "A", statusPassed, 1)},
+			changed:  []statusChange{{"A", statusFailed, statusPassed}},
+		},
+		{
+			name:     "failure waived",
+			baseline: []SpecResult{spec("a", "A", statusFailed, 1)},
+			results:  []SpecResult{spec("a", "A", statusWaived, 1)},
+			changed:  []statusChange{{"A", statusFailed, statusWaived}},
+		},
+		{
+			name:        "added and removed",
+			baseline:    []SpecResult{spec("a", "A", statusPassed, 1)},
+			results:     []SpecResult{spec("b", "B", statusFailed, 1)},
+			changed:     []statusChange{{"B", "", statusFailed}, {"A", statusPassed, ""}},
+			newFailures: []string{"B"},
+		},
+		{
+			name:     "title changed, same spec ID",
+			baseline: []SpecResult{spec("pull/x/get-blob", "Pull x GET blob", statusPassed, 1)},
+			results:  []SpecResult{spec("pull/x/get-blob", "Pull x GET blob.", statusPassed, 1)},
+		},
+		{
+			name:        "title changed, same spec ID, failing",
+			baseline:    []SpecResult{spec("pull/x/get-blob", "Pull x GET blob", statusPassed, 1)},
+			results:     []SpecResult{spec("pull/x/get-blob", "Pull x GET Blob", statusFailed, 1)},
+			changed:     []statusChange{{"Pull x GET Blob", statusPassed, statusFailed}},
+			newFailures: []string{"Pull x GET Blob"},
+		},
+		{
+			name:     "JUnit baseline without IDs",
+			baseline: []SpecResult{spec("", "A", statusPassed, 1), spec("", "B", statusPassed, 1)},
+			results:  []SpecResult{spec("a", "A", statusPassed, 1), spec("b", "B", statusSkipped, 0)},
+			changed:  []statusChange{{"B", statusPassed, statusSkipped}},
+		},
+		{
+			name:      "within the threshold",
+			baseline:  []SpecResult{spec("a", "A", statusPassed, 1)},
+			results:   []SpecResult{spec("a", "A", statusPassed, 1.5)},
+			threshold: 50,
+		},
+		{
+			name:      "above the threshold",
+			baseline:  []SpecResult{spec("a", "A", statusPassed, 1)},
+			results:   []SpecResult{spec("a", "A", statusPassed, 1.6)},
+			threshold: 50,
+			slower:    []string{"A"},
+		},
+		{
+			name:      "below the floor",
+			baseline:  []SpecResult{spec("a", "A", statusPassed, 0.01)},
+			results:   []SpecResult{spec("a", "A", statusPassed, 0.1)},
+			threshold: 50,
+		},
+		{
+			name:      "from nothing",
+			baseline:  []SpecResult{spec("a", "A", statusPassed, 0)},
+			results:   []SpecResult{spec("a", "A", statusPassed, 0.2)},
+			threshold: 50,
+			slower:    []string{"A"},
+		},
+		{
+			name:      "slower but failed",
+			baseline:  []SpecResult{spec("a", "A", statusFailed, 1)},
+			results:   []SpecResult{spec("a", "A", statusFailed, 5)},
+			threshold: 50,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := diffResults(tc.baseline, tc.results, tc.threshold)
+			if len(d.Changed) > 0 || len(tc.changed) > 0 {
+				if !reflect.DeepEqual(d.Changed, tc.changed) {
+					t.Errorf("changed = %v, want %v", d.Changed, tc.changed)
+				}
+			}
+			var newFailures, slower []string
+			for _, r := range d.NewFailures {
+				newFailures = append(newFailures, r.Name)
+			}
+			for _, s := range d.Slower {
+				slower = append(slower, s.Name)
+			}
+			if fmt.Sprint(newFailures) != fmt.Sprint(tc.newFailures) {
+				t.Errorf("new failures = %v, want %v", newFailures, tc.newFailures)
+			}
+			if fmt.Sprint(slower) != fmt.Sprint(tc.slower) {
+				t.Errorf("slower = %v, want %v", slower, tc.slower)
+			}
+			if want := len(tc.newFailures) + len(tc.slower); d.Regressions() != want {
+				t.Errorf("%d regressions, want %d", d.Regressions(), want)
+			}
+		})
+	}
+}
+
+func TestReadResults(t *testing.T) {
+	want := []SpecResult{
+		{Name: "Pull Setup Populate", Status: statusPassed, Seconds: 0.5},
+		{Name: "Pull GET blob", Status: statusFailed, Message: "expected 200", Seconds: 1},
+		{Name: "Push PATCH out of order", Status: statusWaived, Message: "/src/02_push.go:10\nwaived: not validated"},
+		{Name: "Push PUT chunk", Status: statusBlocked, Message: "/src/02_push.go:20\nblocked by \"POST\""},
+		{Name: "Push Mount", Status: statusSkipped, Message: "/src/02_push.go:30\nnot enabled"},
+	}
+	junitCases := `
+		<testcase name="Pull Setup Populate" time="0.5"></testcase>
+		<testcase name="Pull GET blob" time="1"><failure message="expected 200"></failure></testcase>
+		<testcase name="Push PATCH out of order" time="0"><skipped>/src/02_push.go:10&#xA;waived: not validated</skipped></testcase>
+		<testcase name="Push PUT chunk" time="0"><skipped>/src/02_push.go:20&#xA;blocked by &#34;POST&#34;</skipped></testcase>
+		<testcase name="Push Mount" time="0"><skipped message="/src/02_push.go:30&#xA;not enabled"></skipped></testcase>`
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    []SpecResult
+	}{
+		{
+			name: "JSON",
+			content: `{"version": "v1", "specs": [
+				{"id": "pull/setup/populate", "name": "Pull Setup Populate", "status": "passed", "seconds": 0.5},
+				{"id": "pull/get-blob", "name": "Pull GET blob", "status": "failed", "message": "expected 200", "seconds": 1}
+			]}`,
+			want: []SpecResult{
+				{ID: "pull/setup/populate", Name: "Pull Setup Populate", Status: statusPassed, Seconds: 0.5},
+				{ID: "pull/get-blob", Name: "Pull GET blob", Status: statusFailed, Message: "expected 200", Seconds: 1},
+			},
+		},
+		{name: "JUnit testsuite", content: `<testsuite name="conformance">` + junitCases + `</testsuite>`, want: want},
+		{name: "JUnit testsuites", content: `<?xml version="1.0"?><testsuites><testsuite>` + junitCases + `</testsuite></testsuites>`, want: want},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "results")
+			if err := os.WriteFile(filename, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readResults(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("readResults = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+
+	for _, content := range []string{"{", "<testsuite>"} {
+		filename := filepath.Join(t.TempDir(), "results")
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readResults(filename); err == nil {
+			t.Errorf("readResults(%q) did not fail", content)
+		}
+	}
+}
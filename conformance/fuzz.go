@@ -0,0 +1,608 @@
+package conformance
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/bloodorangeio/reggie"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	defaultFuzzSequences = 10
+	defaultFuzzLength    = 20
+
+	// fuzzBlobs and fuzzManifests bound the number of distinct blobs and
+	// manifests a sequence refers to, so that operations often act on
+	// content which earlier operations created or deleted
+	fuzzBlobs     = 6
+	fuzzManifests = 3
+	fuzzTags      = 3
+
+	// fuzzShrinkLimit bounds the number of replays spent shrinking a
+	// failing sequence
+	fuzzShrinkLimit = 100
+)
+
+const (
+	opUploadMonolithic fuzzOpKind = iota
+	opUploadChunked
+	opPatchOutOfOrder
+	opMount
+	opPutManifest
+	opDeleteBlob
+	opDeleteManifest
+	opDeleteTag
+	opGetBlob
+	opGetManifest
+	opGetTag
+	opListTags
+	numFuzzOps
+)
+
+type (
+	fuzzOpKind int
+
+	// fuzzOp is one step of a sequence. Blobs, manifests and tags are
+	// referred to by number, and given content and names when the sequence
+	// is run, so that a sequence can be replayed against a registry which
+	// has already seen it.
+	fuzzOp struct {
+		Kind     fuzzOpKind
+		Blob     int
+		Chunks   []int
+		Manifest int
+		Layers   []int
+		Tag      int
+	}
+
+	// fuzzModel is the state the registry is expected to be in.
+	fuzzModel struct {
+		blobs     map[int]bool
+		mounted   map[int]bool
+		manifests map[string]bool
+		tags      map[int]string
+	}
+
+	// fuzzError is a response which contradicts the model. Its class, such
+	// as "status 404" or "content", tells failures apart while shrinking.
+	fuzzError struct {
+		class string
+		msg   string
+	}
+
+	// fuzzFailure is the first operation of a sequence which failed.
+	fuzzFailure struct {
+		index int
+		op    fuzzOp
+		class string
+		err   error
+	}
+
+	// fuzzRun runs a sequence against the registry, keeping the model up to
+	// date and recording every request made.
+	fuzzRun struct {
+		prefix    string
+		sizes     map[int]int
+		contents  map[int]*TestBlob
+		manifests map[int]*TestBlob
+		model     fuzzModel
+		requests  []string
+	}
+)
+
+var fuzzOpNames = [...]string{
+	opUploadMonolithic: "upload-monolithic",
+	opUploadChunked:    "upload-chunked",
+	opPatchOutOfOrder:  "patch-out-of-order",
+	opMount:            "mount",
+	opPutManifest:      "put-manifest",
+	opDeleteBlob:       "delete-blob",
+	opDeleteManifest:   "delete-manifest",
+	opDeleteTag:        "delete-tag",
+	opGetBlob:          "get-blob",
+	opGetManifest:      "get-manifest",
+	opGetTag:           "get-tag",
+	opListTags:         "list-tags",
+}
+
+func (e *fuzzError) Error() string {
+	return e.msg
+}
+
+func fuzzErrorf(class, format string, args ...interface{}) error {
+	return &fuzzError{class: class, msg: fmt.Sprintf(format, args...)}
+}
+
+func (f *fuzzFailure) Error() string {
+	return fmt.Sprintf("operation %d (%s): %v", f.index, f.op, f.err)
+}
+
+// sameFailure reports whether two sequences failed in the same way: on the
+// same operation, for the same reason.
+func sameFailure(a, b error) bool {
+	var fa, fb *fuzzFailure
+	if !errors.As(a, &fa) || !errors.As(b, &fb) {
+		return false
+	}
+	return fa.op.String() == fb.op.String() && fa.class == fb.class
+}
+
+func (op fuzzOp) String() string {
+	s := fuzzOpNames[op.Kind]
+	switch op.Kind {
+	case opUploadMonolithic, opPatchOutOfOrder, opMount, opDeleteBlob, opGetBlob:
+		s += fmt.Sprintf(" blob=%d", op.Blob)
+	case opUploadChunked:
+		s += fmt.Sprintf(" blob=%d chunks=%v", op.Blob, op.Chunks)
+	case opPutManifest:
+		s += fmt.Sprintf(" manifest=%d layers=%v tag=%d", op.Manifest, op.Layers, op.Tag)
+	case opDeleteManifest, opGetManifest:
+		s += fmt.Sprintf(" manifest=%d", op.Manifest)
+	case opDeleteTag, opGetTag:
+		s += fmt.Sprintf(" tag=%d", op.Tag)
+	}
+	return s
+}
+
+// generateFuzzSequence returns length random operations.
+func generateFuzzSequence(r *rand.Rand, length int, sizes map[int]int) []fuzzOp {
+	// each manifest always refers to the same blobs, a config and one or
+	// two layers, so that its digest does not depend on which operations
+	// were removed while shrinking
+	layers := map[int][]int{}
+	for m := 0; m < fuzzManifests; m++ {
+		layers[m] = r.Perm(fuzzBlobs)[:2+r.Intn(2)]
+	}
+
+	ops := make([]fuzzOp, length)
+	for i := range ops {
+		op := fuzzOp{
+			Kind:     fuzzOpKind(r.Intn(int(numFuzzOps))),
+			Blob:     r.Intn(fuzzBlobs),
+			Manifest: r.Intn(fuzzManifests),
+			Tag:      r.Intn(fuzzTags),
+		}
+		op.Layers = layers[op.Manifest]
+		if op.Kind == opUploadChunked {
+			for remaining := sizes[op.Blob]; remaining > 0; {
+				n := 1 + r.Intn(remaining)
+				op.Chunks = append(op.Chunks, n)
+				remaining -= n
+			}
+		}
+		ops[i] = op
+	}
+	return ops
+}
+
+func newFuzzRun(sizes map[int]int) *fuzzRun {
+	return &fuzzRun{
+		prefix:    strings.ToLower(randomString(8)),
+		sizes:     sizes,
+		contents:  map[int]*TestBlob{},
+		manifests: map[int]*TestBlob{},
+		model: fuzzModel{
+			blobs:     map[int]bool{},
+			mounted:   map[int]bool{},
+			manifests: map[string]bool{},
+			tags:      map[int]string{},
+		},
+	}
+}
+
+// run performs the operations in order, and returns an error describing the
+// first response which contradicts the model.
+func (f *fuzzRun) run(ops []fuzzOp) error {
+	for i, op := range ops {
+		if err := f.step(op); err != nil {
+			failure := &fuzzFailure{index: i, op: op, err: err}
+			var e *fuzzError
+			if errors.As(err, &e) {
+				failure.class = e.class
+			}
+			return failure
+		}
+	}
+	return nil
+}
+
+func (f *fuzzRun) blob(n int) *TestBlob {
+	if _, ok := f.contents[n]; !ok {
+		f.contents[n] = newTestBlob(randomBlob(int64(f.sizes[n])))
+	}
+	return f.contents[n]
+}
+
+func (f *fuzzRun) manifest(op fuzzOp) *TestBlob {
+	if _, ok := f.manifests[op.Manifest]; !ok {
+		config := f.blob(op.Layers[0])
+		var layers []imagespec.Descriptor
+		for _, l := range op.Layers[1:] {
+			layers = append(layers, imagespec.Descriptor{
+				MediaType: "application/vnd.oci.image.layer.v1.tar+gzip",
+				Digest:    godigest.Digest(f.blob(l).Digest),
+				Size:      int64(len(f.blob(l).Content)),
+			})
+		}
+		f.manifests[op.Manifest] = newTestBlob(newManifest(imagespec.Descriptor{
+			MediaType: "application/vnd.oci.image.config.v1+json",
+			Digest:    godigest.Digest(config.Digest),
+			Size:      int64(len(config.Content)),
+		}, layers...))
+	}
+	return f.manifests[op.Manifest]
+}
+
+func (f *fuzzRun) tag(n int) string {
+	return fmt.Sprintf("fuzz-%s-%d", f.prefix, n)
+}
+
+// do sends a request, records it, and treats any 5xx response as a failure.
+func (f *fuzzRun) do(req *reggie.Request) (*reggie.Response, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		f.requests = append(f.requests, fmt.Sprintf("%s %s: %v", req.Method, req.URL, err))
+		return nil, fuzzErrorf("request", "%s %s: %v", req.Method, req.URL, err)
+	}
+	f.requests = append(f.requests, fmt.Sprintf("%s %s -> %d", req.Method, resp.Request.URL, resp.StatusCode()))
+	if resp.StatusCode() >= 500 {
+		return nil, fuzzErrorf(fmt.Sprintf("status %d", resp.StatusCode()), "%s %s: server error %d: %s",
+			req.Method, resp.Request.URL, resp.StatusCode(), bytes.TrimSpace(resp.Body()))
+	}
+	return resp, nil
+}
+
+// expect returns an error unless resp has one of the given status codes.
+func expect(resp *reggie.Response, codes ...int) error {
+	for _, code := range codes {
+		if resp.StatusCode() == code {
+			return nil
+		}
+	}
+	return fuzzErrorf(fmt.Sprintf("status %d", resp.StatusCode()), "%s %s: expected status %v, got %d",
+		resp.Request.Method, resp.Request.URL, codes, resp.StatusCode())
+}
+
+func (f *fuzzRun) step(op fuzzOp) error {
+	switch op.Kind {
+	case opUploadMonolithic:
+		blob := f.blob(op.Blob)
+		resp, err := f.do(client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/"))
+		if err != nil {
+			return err
+		}
+		if err := expect(resp, http.StatusAccepted); err != nil {
+			return err
+		}
+		resp, err = f.do(client.NewRequest(reggie.PUT, resp.GetRelativeLocation()).
+			SetQueryParam("digest", blob.Digest).
+			SetHeader("Content-Type", "application/octet-stream").
+			SetHeader("Content-Length", blob.ContentLength).
+			SetBody(blob.Content))
+		if err != nil {
+			return err
+		}
+		if err := expect(resp, http.StatusCreated); err != nil {
+			return err
+		}
+		f.model.blobs[op.Blob] = true
+
+	case opUploadChunked:
+		blob := f.blob(op.Blob)
+		resp, err := f.do(client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/").
+			SetHeader("Content-Length", "0"))
+		if err != nil {
+			return err
+		}
+		if err := expect(resp, http.StatusAccepted); err != nil {
+			return err
+		}
+		location, offset := resp.GetRelativeLocation(), 0
+		for _, n := range op.Chunks {
+			chunk := blob.Content[offset : offset+n]
+			resp, err = f.do(client.NewRequest(reggie.PATCH, location).
+				SetHeader("Content-Type", "application/octet-stream").
+				SetHeader("Content-Length", strconv.Itoa(n)).
+				SetHeader("Content-Range", fmt.Sprintf("%d-%d", offset, offset+n-1)).
+				SetBody(chunk))
+			if err != nil {
+				return err
+			}
+			if err := expect(resp, http.StatusAccepted); err != nil {
+				return err
+			}
+			offset += n
+			if r := resp.Header().Get("Range"); r != "" && r != fmt.Sprintf("0-%d", offset-1) {
+				return fuzzErrorf("range", "PATCH %s: Range %q after %d bytes", location, r, offset)
+			}
+			location = resp.GetRelativeLocation()
+		}
+		resp, err = f.do(client.NewRequest(reggie.PUT, location).
+			SetQueryParam("digest", blob.Digest).
+			SetHeader("Content-Length", "0"))
+		if err != nil {
+			return err
+		}
+		if err := expect(resp, http.StatusCreated); err != nil {
+			return err
+		}
+		f.model.blobs[op.Blob] = true
+
+	case opPatchOutOfOrder:
+		blob := f.blob(op.Blob)
+		resp, err := f.do(client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/").
+			SetHeader("Content-Length", "0"))
+		if err != nil {
+			return err
+		}
+		if err := expect(resp, http.StatusAccepted); err != nil {
+			return err
+		}
+		// a first chunk which does not start at offset 0
+		start := 1 + len(blob.Content)/2
+		resp, err = f.do(client.NewRequest(reggie.PATCH, resp.GetRelativeLocation()).
+			SetHeader("Content-Type", "application/octet-stream").
+			SetHeader("Content-Length", strconv.Itoa(len(blob.Content)-start)).
+			SetHeader("Content-Range", fmt.Sprintf("%d-%d", start, len(blob.Content)-1)).
+			SetBody(blob.Content[start:]))
+		if err != nil {
+			return err
+		}
+		return expect(resp, http.StatusRequestedRangeNotSatisfiable)
+
+	case opMount:
+		blob := f.blob(op.Blob)
+		resp, err := f.do(client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/",
+			reggie.WithName(crossmountNamespace)).
+			SetQueryParam("mount", blob.Digest).
+			SetQueryParam("from", client.Config.DefaultName))
+		if err != nil {
+			return err
+		}
+		if !f.model.blobs[op.Blob] && !f.model.mounted[op.Blob] {
+			// nothing to mount, so a new upload session is expected
+			return expect(resp, http.StatusAccepted)
+		}
+		if err := expect(resp, http.StatusCreated, http.StatusAccepted); err != nil {
+			return err
+		}
+		if resp.StatusCode() == http.StatusCreated {
+			f.model.mounted[op.Blob] = true
+		}
+
+	case opPutManifest:
+		for _, l := range op.Layers {
+			if !f.model.blobs[l] {
+				// whether dangling manifests are rejected is checked by
+				// the Push workflow
+				return nil
+			}
+		}
+		manifest := f.manifest(op)
+		resp, err := f.do(client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+			reggie.WithReference(f.tag(op.Tag))).
+			SetHeader("Content-Type", "application/vnd.oci.image.manifest.v1+json").
+			SetBody(manifest.Content))
+		if err != nil {
+			return err
+		}
+		if err := expect(resp, http.StatusCreated); err != nil {
+			return err
+		}
+		f.model.manifests[manifest.Digest] = true
+		f.model.tags[op.Tag] = manifest.Digest
+
+	case opDeleteBlob:
+		blob := f.blob(op.Blob)
+		resp, err := f.do(client.NewRequest(reggie.DELETE, "/v2/<name>/blobs/<digest>",
+			reggie.WithDigest(blob.Digest)))
+		if err != nil {
+			return err
+		}
+		if f.model.blobs[op.Blob] {
+			if err := expect(resp, http.StatusAccepted, http.StatusMethodNotAllowed); err != nil {
+				return err
+			}
+		} else if err := expect(resp, http.StatusNotFound, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+		if resp.StatusCode() == http.StatusAccepted {
+			delete(f.model.blobs, op.Blob)
+		}
+
+	case opDeleteManifest:
+		digest := f.manifest(op).Digest
+		ok := f.model.manifests[digest]
+		resp, err := f.do(client.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<digest>",
+			reggie.WithDigest(digest)))
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := expect(resp, http.StatusAccepted, http.StatusMethodNotAllowed); err != nil {
+				return err
+			}
+		} else if err := expect(resp, http.StatusNotFound, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+		if resp.StatusCode() == http.StatusAccepted {
+			delete(f.model.manifests, digest)
+			// registries differ in whether the tags of a deleted manifest
+			// are removed with it, so they are no longer checked
+			for t, d := range f.model.tags {
+				if d == digest {
+					f.model.tags[t] = ""
+				}
+			}
+		}
+
+	case opDeleteTag:
+		resp, err := f.do(client.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<reference>",
+			reggie.WithReference(f.tag(op.Tag))))
+		if err != nil {
+			return err
+		}
+		if digest, ok := f.model.tags[op.Tag]; ok && digest != "" {
+			// deleting by tag is not supported by every registry
+			if err := expect(resp, http.StatusAccepted, http.StatusBadRequest, http.StatusMethodNotAllowed); err != nil {
+				return err
+			}
+		}
+		if resp.StatusCode() == http.StatusAccepted {
+			delete(f.model.tags, op.Tag)
+		}
+
+	case opGetBlob:
+		blob := f.blob(op.Blob)
+		resp, err := f.do(client.NewRequest(reggie.GET, "/v2/<name>/blobs/<digest>",
+			reggie.WithDigest(blob.Digest)))
+		if err != nil {
+			return err
+		}
+		if !f.model.blobs[op.Blob] {
+			return expect(resp, http.StatusNotFound)
+		}
+		if err := expect(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if !bytes.Equal(resp.Body(), blob.Content) {
+			return fuzzErrorf("content", "GET %s: content differs from what was uploaded", resp.Request.URL)
+		}
+
+	case opGetManifest:
+		digest := f.manifest(op).Digest
+		ok := f.model.manifests[digest]
+		resp, err := f.do(client.NewRequest(reggie.GET, "/v2/<name>/manifests/<digest>",
+			reggie.WithDigest(digest)).
+			SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json"))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return expect(resp, http.StatusNotFound)
+		}
+		if err := expect(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if godigest.FromBytes(resp.Body()).String() != digest {
+			return fuzzErrorf("content", "GET %s: content does not match its digest", resp.Request.URL)
+		}
+
+	case opGetTag:
+		digest, ok := f.model.tags[op.Tag]
+		if ok && digest == "" {
+			return nil
+		}
+		resp, err := f.do(client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+			reggie.WithReference(f.tag(op.Tag))).
+			SetHeader("Accept", "application/vnd.oci.image.manifest.v1+json"))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return expect(resp, http.StatusNotFound)
+		}
+		if err := expect(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if got := godigest.FromBytes(resp.Body()).String(); got != digest {
+			return fuzzErrorf("content", "GET %s: tag refers to %s, expected %s", resp.Request.URL, got, digest)
+		}
+
+	case opListTags:
+		listed := map[string]bool{}
+		// the list may be split into pages, each linking to the next
+		for path := "/v2/<name>/tags/list"; path != ""; {
+			resp, err := f.do(client.NewRequest(reggie.GET, path))
+			if err != nil {
+				return err
+			}
+			if len(f.model.tags) == 0 && len(listed) == 0 {
+				// the repository may not exist yet
+				if err := expect(resp, http.StatusOK, http.StatusNotFound); err != nil || resp.StatusCode() == http.StatusNotFound {
+					return err
+				}
+			} else if err := expect(resp, http.StatusOK); err != nil {
+				return err
+			}
+			for _, tag := range getTagList(resp) {
+				listed[tag] = true
+			}
+			if path, err = nextLink(resp); err != nil {
+				return fuzzErrorf("link", "GET %s: %v", resp.Request.URL, err)
+			}
+		}
+		for t := 0; t < fuzzTags; t++ {
+			digest, ok := f.model.tags[t]
+			if ok && digest != "" && !listed[f.tag(t)] {
+				return fuzzErrorf("tags", "tag %s is missing from the tag list", f.tag(t))
+			}
+			if !ok && listed[f.tag(t)] {
+				return fuzzErrorf("tags", "deleted tag %s is in the tag list", f.tag(t))
+			}
+		}
+	}
+	return nil
+}
+
+// nextLink returns the path and query of the next page of a paginated list,
+// taken from the Link header, or "" if this is the last page.
+func nextLink(resp *reggie.Response) (string, error) {
+	for _, link := range resp.Header().Values("Link") {
+		for _, part := range strings.Split(link, ",") {
+			fields := strings.Split(part, ";")
+			target := strings.TrimSpace(fields[0])
+			isNext := false
+			for _, param := range fields[1:] {
+				isNext = isNext || strings.ReplaceAll(strings.TrimSpace(param), " ", "") == `rel="next"`
+			}
+			if !isNext {
+				continue
+			}
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				return "", fmt.Errorf("invalid Link header %q", link)
+			}
+			u, err := url.Parse(strings.Trim(target, "<>"))
+			if err != nil {
+				return "", fmt.Errorf("invalid Link header %q: %v", link, err)
+			}
+			return u.RequestURI(), nil
+		}
+	}
+	return "", nil
+}
+
+// shrinkFuzzSequence removes as many operations as possible from a failing
+// sequence while it keeps failing in the same way, and returns the smallest
+// sequence found along with the run which failed on it. A candidate which
+// fails differently is not taken, so that the failure reported is the one
+// found.
+func shrinkFuzzSequence(ops []fuzzOp, sizes map[int]int, failed *fuzzRun, failure error) ([]fuzzOp, *fuzzRun, error) {
+	replays := 0
+	for shrunk := true; shrunk && replays < fuzzShrinkLimit; {
+		shrunk = false
+		for chunk := len(ops) / 2; chunk >= 1; chunk /= 2 {
+			for i := 0; i+chunk <= len(ops) && replays < fuzzShrinkLimit; {
+				candidate := append(append([]fuzzOp{}, ops[:i]...), ops[i+chunk:]...)
+				replays++
+				run := newFuzzRun(sizes)
+				err := run.run(candidate)
+				tracker.unwind()
+				if err != nil && sameFailure(err, failure) {
+					ops, failed, failure, shrunk = candidate, run, err, true
+					continue
+				}
+				i += chunk
+			}
+		}
+	}
+	return ops, failed, failure
+}
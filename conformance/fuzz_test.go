@@ -0,0 +1,389 @@
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bloodorangeio/reggie"
+	"github.com/go-resty/resty/v2"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+// fakeRegistry is an in-memory registry, just enough of one to run fuzz
+// sequences against. Its bugs can be switched on to check that they are
+// found.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string]map[string][]byte // repository -> digest -> content
+	manifests map[string]map[string][]byte
+	tags      map[string]map[string]string // repository -> tag -> digest
+	uploads   map[string][]byte
+	nextID    int
+
+	// pageSize is the number of tags listed per page, if not 0
+	pageSize int
+	// keepDeletedBlobs makes blob deletes succeed without removing the blob
+	keepDeletedBlobs bool
+	// acceptUnknownDeletes makes deletes of unknown manifests return 202
+	acceptUnknownDeletes bool
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		blobs:     map[string]map[string][]byte{},
+		manifests: map[string]map[string][]byte{},
+		tags:      map[string]map[string]string{},
+		uploads:   map[string][]byte{},
+	}
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	query := r.URL.Query()
+
+	if i := strings.LastIndex(path, "/blobs/uploads/"); i >= 0 {
+		name, id := path[:i], path[i+len("/blobs/uploads/"):]
+		switch {
+		case r.Method == http.MethodPost:
+			if digest := query.Get("mount"); digest != "" {
+				if content, ok := f.blobs[query.Get("from")][digest]; ok {
+					f.putBlob(name, digest, content)
+					w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, digest))
+					w.WriteHeader(http.StatusCreated)
+					return
+				}
+			}
+			f.nextID++
+			id = strconv.Itoa(f.nextID)
+			f.uploads[id] = nil
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPatch:
+			upload, ok := f.uploads[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if cr := r.Header.Get("Content-Range"); cr != "" && !strings.HasPrefix(cr, fmt.Sprintf("%d-", len(upload))) {
+				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			f.uploads[id] = append(upload, body...)
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+			w.Header().Set("Range", fmt.Sprintf("0-%d", len(f.uploads[id])-1))
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodPut:
+			upload, ok := f.uploads[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			content := append(upload, body...)
+			digest := query.Get("digest")
+			if godigest.FromBytes(content).String() != digest {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			delete(f.uploads, id)
+			f.putBlob(name, digest, content)
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, digest))
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if i := strings.LastIndex(path, "/blobs/"); i >= 0 {
+		name, digest := path[:i], path[i+len("/blobs/"):]
+		content, ok := f.blobs[name][digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			w.WriteHeader(http.StatusOK)
+			w.Write(content)
+		case http.MethodDelete:
+			if !f.keepDeletedBlobs {
+				delete(f.blobs[name], digest)
+			}
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if i := strings.LastIndex(path, "/manifests/"); i >= 0 {
+		name, reference := path[:i], path[i+len("/manifests/"):]
+		isDigest := strings.HasPrefix(reference, "sha256:")
+		digest := reference
+		if !isDigest {
+			digest = f.tags[name][reference]
+		}
+		switch r.Method {
+		case http.MethodPut:
+			digest = godigest.FromBytes(body).String()
+			if f.manifests[name] == nil {
+				f.manifests[name] = map[string][]byte{}
+				f.tags[name] = map[string]string{}
+			}
+			f.manifests[name][digest] = body
+			if !isDigest {
+				f.tags[name][reference] = digest
+			}
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet, http.MethodHead:
+			content, ok := f.manifests[name][digest]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(content)
+		case http.MethodDelete:
+			if !isDigest {
+				if _, ok := f.tags[name][reference]; !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				delete(f.tags[name], reference)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			if _, ok := f.manifests[name][digest]; !ok && !f.acceptUnknownDeletes {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(f.manifests[name], digest)
+			for tag, d := range f.tags[name] {
+				if d == digest {
+					delete(f.tags[name], tag)
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if name := strings.TrimSuffix(path, "/tags/list"); name != path && r.Method == http.MethodGet {
+		var tags []string
+		for tag := range f.tags[name] {
+			if tag > query.Get("last") {
+				tags = append(tags, tag)
+			}
+		}
+		sort.Strings(tags)
+		if f.pageSize > 0 && len(tags) > f.pageSize {
+			tags = tags[:f.pageSize]
+			next := url.Values{"n": {strconv.Itoa(f.pageSize)}, "last": {tags[len(tags)-1]}}
+			w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?%s>; rel="next"`, name, next.Encode()))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"name": %q, "tags": [%s]}`, name, quoteAll(tags))
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (f *fakeRegistry) putBlob(name, digest string, content []byte) {
+	if f.blobs[name] == nil {
+		f.blobs[name] = map[string][]byte{}
+	}
+	f.blobs[name][digest] = content
+}
+
+func quoteAll(s []string) string {
+	quoted := make([]string, len(s))
+	for i := range s {
+		quoted[i] = strconv.Quote(s[i])
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// useFakeRegistry points the client the fuzzer uses at reg for the rest of
+// the test.
+func useFakeRegistry(t *testing.T, reg *fakeRegistry) {
+	srv := httptest.NewServer(reg)
+	savedClient, savedTracker, savedNamespace := client, tracker, crossmountNamespace
+	t.Cleanup(func() {
+		srv.Close()
+		client, tracker, crossmountNamespace = savedClient, savedTracker, savedNamespace
+	})
+
+	c, err := reggie.NewClient(srv.URL, reggie.WithDefaultName("fuzz/test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, tracker, crossmountNamespace = c, newResourceTracker(), "fuzz/mount"
+	client.OnAfterResponse(tracker.afterResponse(client))
+}
+
+func fuzzSizes(r *rand.Rand) map[int]int {
+	sizes := map[int]int{}
+	for b := 0; b < fuzzBlobs; b++ {
+		sizes[b] = 2 + r.Intn(64)
+	}
+	return sizes
+}
+
+// Random sequences against a registry without bugs all pass, including the
+// tag lists split across pages.
+func TestFuzzModel(t *testing.T) {
+	for _, pageSize := range []int{0, 1, 2} {
+		reg := newFakeRegistry()
+		reg.pageSize = pageSize
+		useFakeRegistry(t, reg)
+
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 50; i++ {
+			sizes := fuzzSizes(r)
+			ops := generateFuzzSequence(r, 40, sizes)
+			run := newFuzzRun(sizes)
+			if err := run.run(ops); err != nil {
+				t.Fatalf("page size %d, sequence %d: %v\n%s", pageSize, i, err, strings.Join(run.requests, "\n"))
+			}
+			tracker.unwind()
+		}
+	}
+}
+
+func TestFuzzModelFindsBugs(t *testing.T) {
+	sizes := fuzzSizes(rand.New(rand.NewSource(1)))
+	for _, tc := range []struct {
+		name  string
+		bug   func(*fakeRegistry)
+		ops   []fuzzOp
+		class string
+	}{
+		{
+			name: "deleted blob served",
+			bug:  func(reg *fakeRegistry) { reg.keepDeletedBlobs = true },
+			ops: []fuzzOp{
+				{Kind: opUploadMonolithic, Blob: 0},
+				{Kind: opDeleteBlob, Blob: 0},
+				{Kind: opGetBlob, Blob: 0},
+			},
+			class: "status 200",
+		},
+		{
+			name: "unknown manifest deleted",
+			bug:  func(reg *fakeRegistry) { reg.acceptUnknownDeletes = true },
+			ops: []fuzzOp{
+				{Kind: opDeleteManifest, Manifest: 0, Layers: []int{0, 1}},
+			},
+			class: "status 202",
+		},
+		{
+			// not a bug: the links to the later pages are followed
+			name: "tag list split into pages",
+			bug:  func(reg *fakeRegistry) { reg.pageSize = 1 },
+			ops: []fuzzOp{
+				{Kind: opUploadMonolithic, Blob: 0},
+				{Kind: opUploadChunked, Blob: 1, Chunks: []int{1, sizes[1] - 1}},
+				{Kind: opPutManifest, Manifest: 0, Layers: []int{0, 1}, Tag: 0},
+				{Kind: opPutManifest, Manifest: 0, Layers: []int{0, 1}, Tag: 1},
+				{Kind: opListTags},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := newFakeRegistry()
+			tc.bug(reg)
+			useFakeRegistry(t, reg)
+
+			err := newFuzzRun(sizes).run(tc.ops)
+			tracker.unwind()
+			if tc.class == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			failure, ok := err.(*fuzzFailure)
+			if !ok {
+				t.Fatalf("run = %v, want a failure", err)
+			}
+			if last := len(tc.ops) - 1; failure.index != last || failure.class != tc.class {
+				t.Errorf("failed on operation %d with %q, want %d with %q", failure.index, failure.class, last, tc.class)
+			}
+		})
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	for _, tc := range []struct {
+		link    []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "", false},
+		{[]string{`</v2/a/tags/list?n=2&last=b>; rel="next"`}, "/v2/a/tags/list?n=2&last=b", false},
+		{[]string{`<https://registry.example/v2/a/tags/list?last=b>;rel="next"`}, "/v2/a/tags/list?last=b", false},
+		{[]string{`</v2/a/tags/list?last=a>; rel="prev", </v2/a/tags/list?last=c>; rel="next"`}, "/v2/a/tags/list?last=c", false},
+		{[]string{`</v2/a/tags/list?last=a>; rel="prev"`}, "", false},
+		{[]string{`/v2/a/tags/list?last=b; rel="next"`}, "", true},
+	} {
+		resp := &reggie.Response{Response: &resty.Response{RawResponse: &http.Response{Header: http.Header{"Link": tc.link}}}}
+		got, err := nextLink(resp)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("nextLink(%q) = %q, %v, want %q", tc.link, got, err, tc.want)
+		}
+	}
+}
+
+// The shrinker only keeps candidates which fail like the original sequence,
+// even where removing an operation makes an earlier one fail differently.
+func TestShrinkFuzzSequence(t *testing.T) {
+	reg := newFakeRegistry()
+	reg.keepDeletedBlobs = true
+	reg.acceptUnknownDeletes = true
+	useFakeRegistry(t, reg)
+
+	sizes := fuzzSizes(rand.New(rand.NewSource(1)))
+	layers := []int{0, 1}
+	ops := []fuzzOp{
+		{Kind: opUploadMonolithic, Blob: 0},
+		{Kind: opUploadMonolithic, Blob: 1},
+		{Kind: opUploadMonolithic, Blob: 2},
+		{Kind: opPutManifest, Manifest: 0, Layers: layers},
+		// without the manifest, this delete fails instead
+		{Kind: opDeleteManifest, Manifest: 0, Layers: layers},
+		{Kind: opDeleteBlob, Blob: 2},
+		{Kind: opGetBlob, Blob: 2},
+	}
+	run := newFuzzRun(sizes)
+	failure := run.run(ops)
+	tracker.unwind()
+	if failure == nil {
+		t.Fatal("the sequence did not fail")
+	}
+
+	shrunk, _, err := shrinkFuzzSequence(ops, sizes, run, failure)
+	want := []fuzzOp{ops[2], ops[5], ops[6]}
+	if fmt.Sprint(shrunk) != fmt.Sprint(want) {
+		t.Errorf("shrunk to %v, want %v", shrunk, want)
+	}
+	if !sameFailure(err, failure) {
+		t.Errorf("shrunk sequence failed with %v, want %v", err, failure)
+	}
+}
@@ -0,0 +1,64 @@
+package conformance
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/onsi/ginkgo/types"
+)
+
+type (
+	// githubActionsReporter adds the Markdown report to the job summary of a
+	// GitHub Actions step and sets the step outputs declared in action.yml.
+	githubActionsReporter struct {
+		*MarkdownReporter
+		outputFilename string
+	}
+)
+
+// newGitHubActionsReporter returns nil when not running in GitHub Actions.
+func newGitHubActionsReporter() *githubActionsReporter {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return nil
+	}
+
+	return &githubActionsReporter{
+		MarkdownReporter: newMarkdownReporter(os.Getenv("GITHUB_STEP_SUMMARY"), true),
+		outputFilename:   os.Getenv("GITHUB_OUTPUT"),
+	}
+}
+
+func (reporter *githubActionsReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	reporter.MarkdownReporter.SpecSuiteDidEnd(summary)
+	if reporter.outputFilename == "" {
+		return
+	}
+
+	f, err := os.OpenFile(reporter.outputFilename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		reportError(err)
+		return
+	}
+	defer f.Close()
+
+	outputs := []struct {
+		name  string
+		value interface{}
+	}{
+		{"passed", summary.NumberOfPassedSpecs},
+		{"failed", summary.NumberOfFailedSpecs},
+		{"skipped", reporter.NumberOfSkippedSpecs},
+		{"waived", reporter.NumberOfWaivedSpecs},
+		{"blocked", reporter.NumberOfBlockedSpecs},
+		{"junit-report", reportJUnitFilename},
+		{"html-report", reportHTMLFilename},
+		{"markdown-report", reportMarkdownFilename},
+		{"results-report", reportResultsFilename},
+	}
+	for _, o := range outputs {
+		if _, err := fmt.Fprintf(f, "%s=%v\n", o.name, o.value); err != nil {
+			reportError(err)
+			return
+		}
+	}
+}
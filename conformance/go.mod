@@ -4,9 +4,13 @@ go 1.16
 
 require (
 	github.com/bloodorangeio/reggie v0.5.0
+	github.com/go-resty/resty/v2 v2.1.0
 	github.com/google/uuid v1.2.0
 	github.com/onsi/ginkgo v1.15.2
 	github.com/onsi/gomega v1.11.0
+	github.com/opencontainers/distribution-spec v1.0.0-rc0.0.20200108182153-219f20cbcfa1
 	github.com/opencontainers/go-digest v1.0.0
 	github.com/opencontainers/image-spec v1.0.1
 )
+
+replace github.com/opencontainers/distribution-spec => ../
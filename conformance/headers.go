@@ -0,0 +1,196 @@
+package conformance
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bloodorangeio/reggie"
+	"github.com/go-resty/resty/v2"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+const (
+	headerAPIVersion    = "Docker-Distribution-API-Version"
+	headerUploadUUID    = "Docker-Upload-UUID"
+	headerRange         = "Range"
+	headerContentDigest = "Docker-Content-Digest"
+)
+
+var (
+	uploadUUIDPattern = regexp.MustCompile(`^[A-Za-z0-9._~=-]+$`)
+	rangePattern      = regexp.MustCompile(`^0-[0-9]+$`)
+)
+
+type (
+	// legacyHeaderResult counts how often a legacy Docker header was present,
+	// absent or malformed on one kind of response.
+	legacyHeaderResult struct {
+		Header    string `json:"header"`
+		Responses string `json:"responses"`
+		Present   int    `json:"present"`
+		Absent    int    `json:"absent"`
+		Malformed int    `json:"malformed"`
+		Example   string `json:"example,omitempty"`
+	}
+
+	// legacyHeaderChecker inspects every response for the legacy Docker
+	// headers that the specification makes optional, so that the report can
+	// show clients which of them a registry sends.
+	legacyHeaderChecker struct {
+		mu      sync.Mutex
+		results []*legacyHeaderResult
+	}
+)
+
+func newLegacyHeaderChecker() *legacyHeaderChecker {
+	return &legacyHeaderChecker{}
+}
+
+// afterResponse is registered as a resty response middleware and classifies
+// the legacy headers relevant to each response.
+func (c *legacyHeaderChecker) afterResponse(_ *resty.Client, resp *resty.Response) error {
+	req := resp.Request
+	if req == nil || req.RawRequest == nil {
+		return nil
+	}
+	u := req.RawRequest.URL
+	method, status := req.Method, resp.StatusCode()
+
+	switch {
+	case u.Path == "/v2/" && method == reggie.GET:
+		c.check(resp, headerAPIVersion, "GET /v2/", func(v string) error {
+			if v != "registry/2.0" {
+				return fmt.Errorf("expected registry/2.0")
+			}
+			return nil
+		})
+
+	case strings.Contains(u.Path, "/blobs/uploads/") && (status == http.StatusAccepted || status == http.StatusNoContent):
+		responses := fmt.Sprintf("%s blob upload (%d)", method, status)
+		c.check(resp, headerUploadUUID, responses, func(v string) error {
+			if !uploadUUIDPattern.MatchString(v) {
+				return fmt.Errorf("not a single token")
+			}
+			return nil
+		})
+		if method == reggie.PATCH || method == reggie.GET {
+			c.check(resp, headerRange, responses, func(v string) error {
+				if !rangePattern.MatchString(v) {
+					return fmt.Errorf("expected 0-<end>")
+				}
+				return nil
+			})
+		}
+
+	// uploads are completed by PUT, or by a monolithic POST or a mount
+	case strings.Contains(u.Path, "/blobs/uploads/") && (method == reggie.PUT || method == reggie.POST) && status == http.StatusCreated:
+		expected := u.Query().Get("digest")
+		if expected == "" {
+			expected = u.Query().Get("mount")
+		}
+		c.check(resp, headerContentDigest, fmt.Sprintf("%s blob upload (201)", method), func(v string) error {
+			return checkDigestHeader(v, expected)
+		})
+
+	case strings.Contains(u.Path, "/manifests/") && method == reggie.PUT && status == http.StatusCreated:
+		expected := ""
+		if body, ok := req.Body.([]byte); ok {
+			expected = godigest.FromBytes(body).String()
+		}
+		c.check(resp, headerContentDigest, "PUT manifest (201)", func(v string) error {
+			return checkDigestHeader(v, expected)
+		})
+
+	case (method == reggie.GET || method == reggie.HEAD) && status == http.StatusOK:
+		var kind, reference string
+		if i := strings.LastIndex(u.Path, "/blobs/"); i >= 0 {
+			kind, reference = "blob", u.Path[i+len("/blobs/"):]
+		} else if i := strings.LastIndex(u.Path, "/manifests/"); i >= 0 {
+			kind, reference = "manifest", u.Path[i+len("/manifests/"):]
+		} else {
+			return nil
+		}
+		expected := ""
+		if _, err := godigest.Parse(reference); err == nil {
+			expected = reference
+		} else if method == reggie.GET {
+			expected = godigest.FromBytes(resp.Body()).String()
+		}
+		c.check(resp, headerContentDigest, fmt.Sprintf("%s %s (200)", method, kind), func(v string) error {
+			return checkDigestHeader(v, expected)
+		})
+	}
+	return nil
+}
+
+// checkDigestHeader checks that v is a valid digest, equal to expected if
+// that is known.
+func checkDigestHeader(v, expected string) error {
+	if _, err := godigest.Parse(v); err != nil {
+		return err
+	}
+	if expected != "" && v != expected {
+		return fmt.Errorf("expected %s", expected)
+	}
+	return nil
+}
+
+// check records whether header is present on resp, and if so whether
+// validate accepts its value.
+func (c *legacyHeaderChecker) check(resp *resty.Response, header, responses string, validate func(string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var result *legacyHeaderResult
+	for _, r := range c.results {
+		if r.Header == header && r.Responses == responses {
+			result = r
+			break
+		}
+	}
+	if result == nil {
+		result = &legacyHeaderResult{Header: header, Responses: responses}
+		c.results = append(c.results, result)
+	}
+
+	v := resp.Header().Get(header)
+	if v == "" {
+		result.Absent++
+		return
+	}
+	if err := validate(v); err != nil {
+		result.Malformed++
+		if result.Example == "" {
+			result.Example = fmt.Sprintf("%q: %v", v, err)
+		}
+		return
+	}
+	result.Present++
+}
+
+func (c *legacyHeaderChecker) list() []*legacyHeaderResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	results := make([]*legacyHeaderResult, len(c.results))
+	for i, r := range c.results {
+		copied := *r
+		results[i] = &copied
+	}
+	return results
+}
+
+// Status summarizes the result as present, absent or malformed; a header
+// which was malformed on any response is reported as malformed.
+func (r *legacyHeaderResult) Status() string {
+	switch {
+	case r.Malformed > 0:
+		return "malformed"
+	case r.Present > 0:
+		return "present"
+	default:
+		return "absent"
+	}
+}
@@ -0,0 +1,94 @@
+package conformance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+func TestLegacyHeaderChecker(t *testing.T) {
+	// the server answers with the status and header asked for in the request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := r.Header.Get("X-Header"); name != "" {
+			w.Header().Set(name, r.Header.Get("X-Value"))
+		}
+		status := http.StatusOK
+		switch r.Header.Get("X-Status") {
+		case "201":
+			status = http.StatusCreated
+		case "202":
+			status = http.StatusAccepted
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("content"))
+	}))
+	defer srv.Close()
+
+	digest := godigest.FromString("content").String()
+	manifest := []byte(`{"schemaVersion":2}`)
+	otherDigest := godigest.FromString("other").String()
+
+	checker := newLegacyHeaderChecker()
+	c := resty.New().SetHostURL(srv.URL).OnAfterResponse(checker.afterResponse)
+	for _, tc := range []struct {
+		method, path, status, header, value string
+		body                                []byte
+	}{
+		{"GET", "/v2/", "", headerAPIVersion, "registry/2.0", nil},
+		{"GET", "/v2/", "", headerAPIVersion, "registry/1.0", nil},
+		{"GET", "/v2/", "", "", "", nil},
+		{"POST", "/v2/a/blobs/uploads/?digest=" + digest, "201", headerContentDigest, digest, nil},
+		{"POST", "/v2/a/blobs/uploads/?digest=" + digest, "201", headerContentDigest, otherDigest, nil},
+		{"POST", "/v2/a/blobs/uploads/?mount=" + digest + "&from=b", "201", headerContentDigest, digest, nil},
+		{"POST", "/v2/a/blobs/uploads/?digest=" + digest, "201", "", "", nil},
+		{"PUT", "/v2/a/blobs/uploads/1?digest=" + digest, "201", headerContentDigest, digest, nil},
+		{"PUT", "/v2/a/blobs/uploads/1?digest=" + digest, "201", headerContentDigest, "not a digest", nil},
+		{"PATCH", "/v2/a/blobs/uploads/1", "202", headerRange, "0-6", nil},
+		{"PATCH", "/v2/a/blobs/uploads/1", "202", headerRange, "bytes=0-6", nil},
+		{"PUT", "/v2/a/manifests/latest", "201", headerContentDigest, godigest.FromBytes(manifest).String(), manifest},
+		{"GET", "/v2/a/blobs/" + digest, "", headerContentDigest, otherDigest, nil},
+		{"GET", "/v2/a/manifests/latest", "", headerContentDigest, digest, nil},
+		{"HEAD", "/v2/a/manifests/latest", "", headerContentDigest, digest, nil},
+	} {
+		req := c.R().SetHeaders(map[string]string{"X-Status": tc.status, "X-Header": tc.header, "X-Value": tc.value})
+		if tc.body != nil {
+			req.SetBody(tc.body)
+		}
+		if _, err := req.Execute(tc.method, tc.path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string][3]int{
+		headerAPIVersion + " on GET /v2/":                  {1, 1, 1},
+		headerContentDigest + " on POST blob upload (201)": {2, 1, 1},
+		headerContentDigest + " on PUT blob upload (201)":  {1, 0, 1},
+		headerUploadUUID + " on PATCH blob upload (202)":   {0, 2, 0},
+		headerRange + " on PATCH blob upload (202)":        {1, 0, 1},
+		headerContentDigest + " on PUT manifest (201)":     {1, 0, 0},
+		headerContentDigest + " on GET blob (200)":         {0, 0, 1},
+		headerContentDigest + " on GET manifest (200)":     {1, 0, 0},
+		headerContentDigest + " on HEAD manifest (200)":    {1, 0, 0},
+	}
+	results := checker.list()
+	for _, r := range results {
+		key := r.Header + " on " + r.Responses
+		counts, ok := want[key]
+		if !ok {
+			t.Errorf("unexpected result for %s", key)
+			continue
+		}
+		if got := [3]int{r.Present, r.Absent, r.Malformed}; got != counts {
+			t.Errorf("%s: present, absent, malformed = %v, want %v", key, got, counts)
+		}
+		if counts[2] > 0 && (r.Status() != "malformed" || r.Example == "") {
+			t.Errorf("%s: status %s, example %q", key, r.Status(), r.Example)
+		}
+	}
+	if len(results) != len(want) {
+		t.Errorf("%d results, want %d", len(results), len(want))
+	}
+}
@@ -0,0 +1,203 @@
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+const (
+	markdownTemplate string = `# OCI Distribution Conformance Tests
+
+{{ .SuiteSummary.NumberOfPassedSpecs }} passed, {{ .SuiteSummary.NumberOfFailedSpecs }} failed, {{ .NumberOfSkippedSpecs }} skipped
+{{- if gt .NumberOfWaivedSpecs 0 }}, {{ .NumberOfWaivedSpecs }} waived{{ end }}
+{{- if gt .NumberOfBlockedSpecs 0 }}, {{ .NumberOfBlockedSpecs }} blocked{{ end }}
+
+| | |
+|---|---|
+| Start Time | {{ .StartTimeString }} |
+| Time Elapsed | {{ .RunTime }} |
+| Test Version | {{ .Version }} |
+{{ with .Suite }}
+{{- $suite := .M }}
+{{- range $i, $suiteKey := .Keys }}
+{{- $wf := index $suite $suiteKey }}
+{{- if $wf.IsEnabled }}
+## {{ $suiteKey }}
+{{ $workflow := $wf.M }}
+{{- range $j, $workflowKey := $wf.Keys }}
+{{- $ctg := index $workflow $workflowKey }}
+### {{ $workflowKey }}
+{{ $category := $ctg.M }}
+{{- range $k, $categoryKey := $ctg.Keys }}
+{{- $s := index $category $categoryKey }}
+- {{ markdownStatus $s }} {{ $s.Title }}
+{{- if $s.HasFailureState }}
+  <details><summary>Failure</summary>
+
+  ` + "```" + `
+{{ indent $s.Failure.Message }}
+  ` + "```" + `
+  </details>
+{{- else if $s.IsWaived }}: {{ trimWaived $s.Failure.Message }}
+{{- else if $s.IsBlocked }}: {{ $s.Failure.Message }}
+{{- end }}
+{{- end }}
+{{ end }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- if .LegacyHeaders }}
+## Legacy Headers
+
+| Header | Responses | Status | Present | Absent | Malformed | Example |
+|---|---|---|---|---|---|---|
+{{- range .LegacyHeaders }}
+| {{ .Header }} | {{ .Responses }} | {{ .Status }} | {{ .Present }} | {{ .Absent }} | {{ .Malformed }} | {{ .Example }} |
+{{- end }}
+{{ end }}
+`
+)
+
+type (
+	// MarkdownReporter renders the results as a Markdown document, using the
+	// same workflow and category tree as the HTMLReporter.
+	MarkdownReporter struct {
+		markdownReportFilename string
+		appendToFile           bool
+		Suite                  suite
+		SuiteSummary           *types.SuiteSummary
+		enabledMap             map[string]bool
+		startTime              time.Time
+		StartTimeString        string
+		RunTime                string
+		NumberOfSkippedSpecs   int
+		NumberOfWaivedSpecs    int
+		NumberOfBlockedSpecs   int
+		LegacyHeaders          []*legacyHeaderResult
+		Version                string
+	}
+)
+
+// newMarkdownReporter returns a reporter that writes to the given file, or
+// nowhere if the filename is empty. If appendToFile is set, an existing file
+// is added to rather than replaced, as is expected for GITHUB_STEP_SUMMARY.
+func newMarkdownReporter(markdownReportFilename string, appendToFile bool) *MarkdownReporter {
+	return &MarkdownReporter{
+		markdownReportFilename: markdownReportFilename,
+		appendToFile:           appendToFile,
+		enabledMap:             newEnabledMap(),
+		Suite: suite{
+			M:    make(map[string]*workflow),
+			Keys: []string{},
+		},
+	}
+}
+
+func (reporter *MarkdownReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+	reporter.startTime = time.Now()
+	reporter.StartTimeString = reporter.startTime.Format("Jan 2 15:04:05.000 -0700 MST")
+	reporter.Version = Version
+}
+
+func (reporter *MarkdownReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+	snapshot := newSpecSnapshot(specSummary, reporter.Suite.Size)
+	reporter.Suite.add(snapshot, reporter.enabledMap)
+	if snapshot.IsWaived {
+		reporter.NumberOfWaivedSpecs++
+	}
+	if snapshot.IsBlocked {
+		reporter.NumberOfBlockedSpecs++
+	}
+}
+
+func (reporter *MarkdownReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	reporter.RunTime = time.Since(reporter.startTime).String()
+	reporter.SuiteSummary = summary
+	reporter.LegacyHeaders = legacyHeaders.list()
+	reporter.NumberOfSkippedSpecs = summary.NumberOfSkippedSpecs - reporter.NumberOfWaivedSpecs - reporter.NumberOfBlockedSpecs
+	if reporter.markdownReportFilename == "" {
+		return
+	}
+
+	b, err := reporter.render()
+	if err != nil {
+		reportError(err)
+		return
+	}
+
+	markdownReportFilenameAbsPath, err := filepath.Abs(reporter.markdownReportFilename)
+	if err != nil {
+		reportError(err)
+		return
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if reporter.appendToFile {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	markdownReportFile, err := os.OpenFile(markdownReportFilenameAbsPath, flags, 0644)
+	if err != nil {
+		reportError(err)
+		return
+	}
+	defer markdownReportFile.Close()
+
+	if _, err := markdownReportFile.Write(b); err != nil {
+		reportError(err)
+		return
+	}
+
+	fmt.Printf("Markdown report was created: %s\n", markdownReportFilenameAbsPath)
+}
+
+func (reporter *MarkdownReporter) render() ([]byte, error) {
+	t, err := template.New("report").Funcs(template.FuncMap{
+		"markdownStatus": markdownStatus,
+		"trimWaived":     func(s string) string { return strings.TrimPrefix(s, waivedPrefix) },
+		"indent": func(s string) string {
+			return "  " + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n  ")
+		},
+	}).Parse(markdownTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(bytes.Buffer)
+	if err := t.Execute(b, reporter); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (reporter *MarkdownReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (reporter *MarkdownReporter) SpecWillRun(specSummary *types.SpecSummary) {
+}
+
+func (reporter *MarkdownReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+// markdownStatus returns a short marker for the outcome of a spec.
+func markdownStatus(s specSnapshot) string {
+	switch {
+	case s.Passed():
+		return ":white_check_mark:"
+	case s.Failed(), s.Panicked(), s.TimedOut():
+		return ":x:"
+	case s.IsWaived:
+		return ":warning: waived"
+	case s.IsBlocked:
+		return ":no_entry:"
+	default:
+		return ":fast_forward: skipped"
+	}
+}
@@ -0,0 +1,222 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bloodorangeio/reggie"
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+	specs "github.com/opencontainers/distribution-spec/specs-go"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	resultsConfigMediaType = "application/vnd.opencontainers.distribution-spec.conformance.results.v1+json"
+	resultsLogFilename     = "requests.log"
+)
+
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+type (
+	// resultsArtifactConfig is the config of a published results artifact,
+	// so that runs can be told apart without fetching their reports.
+	resultsArtifactConfig struct {
+		SuiteVersion string         `json:"suiteVersion"`
+		SpecVersion  string         `json:"specVersion"`
+		Created      string         `json:"created"`
+		Registry     string         `json:"registry"`
+		Namespace    string         `json:"namespace"`
+		Summary      map[string]int `json:"summary"`
+	}
+
+	// resultsFile is a report to publish, along with its media type.
+	resultsFile struct {
+		filename  string
+		mediaType string
+	}
+
+	// PublishReporter pushes the reports of a run, as an OCI artifact, to a
+	// repository of the registry under test. It must run after the reporters
+	// whose files it publishes.
+	PublishReporter struct {
+		namespace string
+		results   *ResultsReporter
+	}
+)
+
+func newPublishReporter(namespace string, results *ResultsReporter) *PublishReporter {
+	return &PublishReporter{namespace: namespace, results: results}
+}
+
+func (reporter *PublishReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	created := time.Now().UTC()
+	cfg := resultsArtifactConfig{
+		SuiteVersion: Version,
+		SpecVersion:  specs.Version,
+		Created:      created.Format(time.RFC3339),
+		Registry:     client.Config.Address,
+		Namespace:    client.Config.DefaultName,
+		Summary:      map[string]int{},
+	}
+	for _, r := range reporter.results.results.Specs {
+		cfg.Summary[r.Status]++
+	}
+	content, err := json.Marshal(&cfg)
+	if err != nil {
+		reportError(err)
+		return
+	}
+	configBlob := newTestBlob(content)
+
+	files := []resultsFile{
+		{reportHTMLFilename, "text/html"},
+		{reportJUnitFilename, "application/xml"},
+		{reportMarkdownFilename, "text/markdown"},
+		{reportResultsFilename, "application/json"},
+		{bundleFilename, "application/vnd.oci.image.layer.v1.tar+gzip"},
+	}
+	if len(benchmarks.list()) > 0 {
+		// otherwise the file may be left over from an earlier run
+		files = append(files, resultsFile{reportBenchmarkFilename, "application/json"})
+	}
+
+	blobs := []*TestBlob{configBlob}
+	var layers []imagespec.Descriptor
+	for _, f := range files {
+		if f.filename == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(f.filename)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			reportError(err)
+			return
+		}
+		blob := newTestBlob(content)
+		blobs = append(blobs, blob)
+		layers = append(layers, imagespec.Descriptor{
+			MediaType:   f.mediaType,
+			Digest:      godigest.Digest(blob.Digest),
+			Size:        int64(len(content)),
+			Annotations: map[string]string{imagespec.AnnotationTitle: filepath.Base(f.filename)},
+		})
+	}
+	if httpWriter != nil && len(httpWriter.CapturedOutput) > 0 {
+		blob := newTestBlob([]byte(strings.Join(httpWriter.CapturedOutput, "\n")))
+		blobs = append(blobs, blob)
+		layers = append(layers, imagespec.Descriptor{
+			MediaType:   "text/plain",
+			Digest:      godigest.Digest(blob.Digest),
+			Size:        int64(len(blob.Content)),
+			Annotations: map[string]string{imagespec.AnnotationTitle: resultsLogFilename},
+		})
+	}
+
+	manifest := imagespec.Manifest{
+		Config: imagespec.Descriptor{
+			MediaType: resultsConfigMediaType,
+			Digest:    godigest.Digest(configBlob.Digest),
+			Size:      int64(len(configBlob.Content)),
+		},
+		Layers: layers,
+		Annotations: map[string]string{
+			imagespec.AnnotationCreated: cfg.Created,
+			imagespec.AnnotationVersion: Version,
+		},
+	}
+	manifest.SchemaVersion = 2
+	content, err = json.MarshalIndent(&manifest, "", "\t")
+	if err != nil {
+		reportError(err)
+		return
+	}
+
+	tag := resultsTag(created, Version)
+	digest, err := publishResults(reporter.namespace, tag, blobs, content)
+	if err != nil {
+		reportError(fmt.Errorf("publishing results to %s: %v", reporter.namespace, err))
+		return
+	}
+
+	fmt.Printf("Results were published: %s:%s@%s\n", reporter.namespace, tag, digest)
+}
+
+func (reporter *PublishReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (reporter *PublishReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (reporter *PublishReporter) SpecWillRun(specSummary *types.SpecSummary) {
+}
+
+func (reporter *PublishReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+}
+
+func (reporter *PublishReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+// resultsTag names a run by its date and suite version, e.g.
+// 20210401-153000-v1.0.0.
+func resultsTag(created time.Time, version string) string {
+	tag := created.Format("20060102-150405") + "-" + invalidTagChars.ReplaceAllString(version, "-")
+	if len(tag) > 128 {
+		tag = tag[:128]
+	}
+	return tag
+}
+
+// publishResults pushes the blobs and manifest of the results artifact to
+// namespace, and reads the manifest back to check that it was stored as
+// pushed. It returns the digest of the manifest.
+func publishResults(namespace, tag string, blobs []*TestBlob, manifest []byte) (string, error) {
+	for _, blob := range blobs {
+		req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/", reggie.WithName(namespace))
+		resp, err := client.Do(req)
+		if err := checkStatus(resp, err, http.StatusAccepted); err != nil {
+			return "", err
+		}
+		req = client.NewRequest(reggie.PUT, resp.GetRelativeLocation(), reggie.WithRetryCallback(resetBody(blob.Content))).
+			SetQueryParam("digest", blob.Digest).
+			SetHeader("Content-Type", "application/octet-stream").
+			SetHeader("Content-Length", blob.ContentLength).
+			SetBody(bytes.NewReader(blob.Content))
+		resp, err = client.Do(req)
+		if err := checkStatus(resp, err, http.StatusCreated); err != nil {
+			return "", err
+		}
+	}
+
+	req := client.NewRequest(reggie.PUT, "/v2/<name>/manifests/<reference>",
+		reggie.WithName(namespace), reggie.WithReference(tag)).
+		SetHeader("Content-Type", imagespec.MediaTypeImageManifest).
+		SetBody(manifest)
+	resp, err := client.Do(req)
+	if err := checkStatus(resp, err, http.StatusCreated); err != nil {
+		return "", err
+	}
+
+	digest := godigest.FromBytes(manifest).String()
+	req = client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+		reggie.WithName(namespace), reggie.WithReference(tag)).
+		SetHeader("Accept", imagespec.MediaTypeImageManifest)
+	resp, err = client.Do(req)
+	if err := checkStatus(resp, err, http.StatusOK); err != nil {
+		return "", err
+	}
+	if got := godigest.FromBytes(resp.Body()).String(); got != digest {
+		return "", fmt.Errorf("%s:%s was stored as %s, expected %s", namespace, tag, got, digest)
+	}
+	return digest, nil
+}
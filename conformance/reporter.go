@@ -10,7 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/onsi/ginkgo/config"
@@ -73,6 +73,15 @@ const (
         background: lightgrey;
         padding: 1.25em 0 1.25em 0.8em;
       }
+      .yellow {
+        background: #fff3c8;
+        padding: 1.25em 0 1.25em 0.8em;
+      }
+      .spec-id {
+        font-family: monospace;
+        color: #3e3e3e;
+        padding: 0 0 0 1em;
+      }
       .toggle {
         border: 2px solid #3e3e3e;
         cursor: pointer;
@@ -144,6 +153,10 @@ const (
         color: grey;
         padding: 0 0 0 2em;
       }
+      .darkyellow {
+        color: #b08800;
+        padding: 0 0 0 2em;
+      }
       .meter {
         border: 1px solid black;
         margin: 0 .5em 0 auto;
@@ -216,9 +229,15 @@ const (
               <span class="darkred">
               {{- if .AllFailed -}}All {{ end -}}{{ .SuiteSummary.NumberOfFailedSpecs }} failed</span>
             {{- end -}}
-            {{- if gt .SuiteSummary.NumberOfSkippedSpecs 0 -}}
+            {{- if gt .NumberOfSkippedSpecs 0 -}}
               <span class="darkgrey">
-              {{- if .AllSkipped -}}All {{ end -}}{{ .SuiteSummary.NumberOfSkippedSpecs }} skipped</span>
+              {{- if .AllSkipped -}}All {{ end -}}{{ .NumberOfSkippedSpecs }} skipped</span>
+            {{- end -}}
+            {{- if gt .NumberOfWaivedSpecs 0 -}}
+              <span class="darkyellow">{{ .NumberOfWaivedSpecs }} waived</span>
+            {{- end -}}
+            {{- if gt .NumberOfBlockedSpecs 0 -}}
+              <span class="darkgrey">{{ .NumberOfBlockedSpecs }} blocked</span>
             {{- end -}}
             <div class="meter">
               <div class="meter-green"></div>
@@ -275,6 +294,7 @@ const (
                       <div class="result red">
                         <div id="output-box-{{$s.ID}}-button" class="toggle" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">+</div>
                         <h4 style="display: inline;" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">{{$s.Title}}</h4>
+                        <span class="spec-id">{{$s.SpecID}}</span>
                         <br>
                         <div>
                           <div id="output-box-{{$s.ID}}" style="display: none;">
@@ -288,15 +308,37 @@ const (
                       <div class="result green">
                         <div id="output-box-{{$s.ID}}-button" class="toggle" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">+</div>
                         <h4 style="display: inline;" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">{{$s.Title}}</h4>
+                        <span class="spec-id">{{$s.SpecID}}</span>
                         <br>
                         <div id="output-box-{{$s.ID}}" style="display: none;">
                           <pre class="pre-box">{{$s.CapturedOutput}}</pre>
                         </div>
                       </div>
+                    {{else if and (eq $s.State 2) $s.IsWaived}}
+                      <div class="result yellow">
+                        <div id="output-box-{{$s.ID}}-button" class="toggle" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">+</div>
+                        <h4 style="display: inline;" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">{{$s.Title}} (waived)</h4>
+                        <span class="spec-id">{{$s.SpecID}}</span>
+                        <br>
+                        <div id="output-box-{{$s.ID}}" style="display: none;">
+                          <pre class="pre-box">{{$s.Failure.Message}}</pre>
+                        </div>
+                      </div>
+                    {{else if and (eq $s.State 2) $s.IsBlocked}}
+                      <div class="result grey">
+                        <div id="output-box-{{$s.ID}}-button" class="toggle" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">+</div>
+                        <h4 style="display: inline;" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">{{$s.Title}} (blocked)</h4>
+                        <span class="spec-id">{{$s.SpecID}}</span>
+                        <br>
+                        <div id="output-box-{{$s.ID}}" style="display: none;">
+                          <pre class="pre-box">{{$s.Failure.Message}}</pre>
+                        </div>
+                      </div>
                     {{else if eq $s.State 2}}
                       <div class="result grey">
                         <div id="output-box-{{$s.ID}}-button" class="toggle" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">+</div>
                         <h4 style="display: inline;" onclick="javascript:toggleOutput('output-box-{{$s.ID}}')">{{$s.Title}}</h4>
+                        <span class="spec-id">{{$s.SpecID}}</span>
                         <br>
                         <div id="output-box-{{$s.ID}}" style="display: none;">
                           <pre class="pre-box">{{$s.Failure.Message}}</pre>
@@ -312,6 +354,64 @@ const (
         {{end}}
       {{end}}
     </div>
+    {{ if .LegacyHeaders }}
+      <h2>Legacy Headers</h2>
+      <div class="subcategory">
+        <table>
+          <tr>
+            <th>Header</th>
+            <th>Responses</th>
+            <th>Status</th>
+            <th>Present</th>
+            <th>Absent</th>
+            <th>Malformed</th>
+            <th>Example</th>
+          </tr>
+          {{ range $i, $h := .LegacyHeaders }}
+          <tr>
+            <td>{{ $h.Header }}</td>
+            <td>{{ $h.Responses }}</td>
+            <td>{{ $h.Status }}</td>
+            <td>{{ $h.Present }}</td>
+            <td>{{ $h.Absent }}</td>
+            <td>{{ $h.Malformed }}</td>
+            <td>{{ $h.Example }}</td>
+          </tr>
+          {{ end }}
+        </table>
+      </div>
+    {{ end }}
+    {{ if .Benchmarks }}
+      <h2>Benchmark Results</h2>
+      <div class="subcategory">
+        <table>
+          <tr>
+            <th>Endpoint</th>
+            <th>Blob Size</th>
+            <th>Iterations</th>
+            <th>Concurrency</th>
+            <th>p50 (ms)</th>
+            <th>p95 (ms)</th>
+            <th>p99 (ms)</th>
+            <th>MB/s</th>
+            <th>Errors</th>
+          </tr>
+          {{ range $i, $b := .Benchmarks }}
+          <tr>
+            <td>{{ $b.Endpoint }}</td>
+            <td>{{ $b.BlobSizeString }}</td>
+            <td>{{ $b.Iterations }}</td>
+            <td>{{ $b.Concurrency }}</td>
+            <td>{{ printf "%.2f" $b.P50Milliseconds }}</td>
+            <td>{{ printf "%.2f" $b.P95Milliseconds }}</td>
+            <td>{{ printf "%.2f" $b.P99Milliseconds }}</td>
+            <td>{{ if $b.MegabytesPerSec }}{{ printf "%.2f" $b.MegabytesPerSec }}{{ else }}-{{ end }}</td>
+            <td>{{ $b.Errors }}</td>
+          </tr>
+          {{ end }}
+        </table>
+      </div>
+    {{ end }}
   </body>
 </html>
 `
@@ -343,11 +443,14 @@ type (
 
 	specSnapshot struct {
 		types.SpecSummary
-		ID       int
-		Title    string
-		Category string
-		Suite    string
-		IsSetup  bool
+		ID        int
+		Title     string
+		Category  string
+		Suite     string
+		SpecID    string
+		IsSetup   bool
+		IsWaived  bool
+		IsBlocked bool
 	}
 
 	snapShotList []specSnapshot
@@ -355,6 +458,7 @@ type (
 	httpDebugWriter struct {
 		CapturedOutput []string
 		debug          bool
+		mu             sync.Mutex
 	}
 
 	httpDebugLogger struct {
@@ -382,6 +486,11 @@ type (
 		AllPassed            bool
 		AllFailed            bool
 		AllSkipped           bool
+		NumberOfSkippedSpecs int
+		NumberOfWaivedSpecs  int
+		NumberOfBlockedSpecs int
+		Benchmarks           []*benchmarkResult
+		LegacyHeaders        []*legacyHeaderResult
 		Version              string
 	}
 )
@@ -414,8 +523,38 @@ func newSpecSnapshot(sum *types.SpecSummary, id int) *specSnapshot {
 	if category == setupString {
 		isSetup = true
 	}
+	isWaived := sum.State == types.SpecStateSkipped && isWaived(sum.Failure.Message)
+	isBlocked := sum.State == types.SpecStateSkipped && isBlocked(sum.Failure.Message)
 	return &specSnapshot{SpecSummary: *sum, Title: title, ID: id, IsSetup: isSetup, Category: category,
-		Suite: suite}
+		Suite: suite, SpecID: specID(suite, category, title), IsWaived: isWaived, IsBlocked: isBlocked}
+}
+
+var (
+	// credentialFieldPattern matches the values of fields named like
+	// credentials, e.g. a token in a JSON body or the upload state in a URL
+	credentialFieldPattern = regexp.MustCompile("(?i)(\"?\\w*(authorization|token|state)\\w*\"?(:|=)\\s*)(\")?\\s*((bearer|basic)? )?[^\\s&\"]*(\")?")
+	// credentialHeaderPattern matches the headers of the HTTP log which carry
+	// credentials
+	credentialHeaderPattern = regexp.MustCompile(`(?im)^(\s*(proxy-authorization|authorization|cookie|set-cookie)\s*:).*$`)
+	// responseBodyPattern matches the body of a response in the HTTP log
+	responseBodyPattern = regexp.MustCompile(`(?s)(~~~ RESPONSE ~~~\n.*?\nBODY *:\n)(.*?)(\n={10,})`)
+	// tokenFieldPattern matches the fields of a token response
+	tokenFieldPattern = regexp.MustCompile(`"(access_|refresh_)?token"\s*:`)
+)
+
+// redactHTTPLog removes credentials from output of the HTTP client, before
+// it is captured for the reports: the values of headers and fields carrying
+// them, and the whole body of token responses.
+func redactHTTPLog(s string) string {
+	s = credentialHeaderPattern.ReplaceAllString(s, "$1 *****")
+	s = responseBodyPattern.ReplaceAllStringFunc(s, func(response string) string {
+		m := responseBodyPattern.FindStringSubmatch(response)
+		if !tokenFieldPattern.MatchString(m[2]) {
+			return response
+		}
+		return m[1] + "***** TOKEN RESPONSE *****" + m[3]
+	})
+	return credentialFieldPattern.ReplaceAllString(s, "$1$4$5*****$7")
 }
 
 func newHTTPDebugWriter(debug bool) *httpDebugWriter {
@@ -423,7 +562,10 @@ func newHTTPDebugWriter(debug bool) *httpDebugWriter {
 }
 
 func (writer *httpDebugWriter) Write(b []byte) (int, error) {
-	s := string(b)
+	s := redactHTTPLog(string(b))
+	// requests may be made concurrently, e.g. by the benchmark workflow
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
 	writer.CapturedOutput = append(writer.CapturedOutput, s)
 	if writer.debug {
 		fmt.Println(s)
@@ -446,8 +588,6 @@ func (l *httpDebugLogger) Warnf(format string, v ...interface{}) {
 }
 
 func (l *httpDebugLogger) Debugf(format string, v ...interface{}) {
-	re := regexp.MustCompile("(?i)(\"?\\w*(authorization|token|state)\\w*\"?(:|=)\\s*)(\")?\\s*((bearer|basic)? )?[^\\s&\"]*(\")?")
-	format = re.ReplaceAllString(format, "$1$4$5*****$7")
 	l.output("DEBUG "+format, v...)
 }
 
@@ -463,32 +603,49 @@ func (l *httpDebugLogger) output(format string, v ...interface{}) {
 }
 
 func newHTMLReporter(htmlReportFilename string) (h *HTMLReporter) {
+	return &HTMLReporter{
+		htmlReportFilename: htmlReportFilename,
+		debugLogger:        httpWriter,
+		enabledMap:         newEnabledMap(),
+		SpecSummaryMap:     summaryMap{M: make(map[string]snapShotList)},
+		Suite: suite{
+			M:    make(map[string]*workflow),
+			Keys: []string{},
+		},
+	}
+}
+
+// newEnabledMap returns which workflows should be shown in reports.
+func newEnabledMap() map[string]bool {
 	enabledMap := map[string]bool{
 		titlePull:              true,
 		titlePush:              true,
 		titleContentDiscovery:  true,
 		titleContentManagement: true,
+		titleBenchmark:         true,
+		titleTagMutability:     true,
+		titleArtifacts:         true,
+		titleReferrers:         true,
+		titleDockerSchema2:     true,
+		titleMirror:            true,
 	}
 
-	if os.Getenv(envVarHideSkippedWorkflows) == "1" {
+	if hideSkippedWorkflows {
 		enabledMap = map[string]bool{
 			titlePull:              !userDisabled(pull),
 			titlePush:              !userDisabled(push),
 			titleContentDiscovery:  !userDisabled(contentDiscovery),
 			titleContentManagement: !userDisabled(contentManagement),
+			titleBenchmark:         !userDisabled(benchmark),
+			titleTagMutability:     !userDisabled(tagMutability),
+			titleArtifacts:         !userDisabled(artifacts),
+			titleReferrers:         !userDisabled(referrers),
+			titleDockerSchema2:     !userDisabled(dockerSchema2),
+			titleMirror:            !userDisabled(mirror),
 		}
 	}
 
-	return &HTMLReporter{
-		htmlReportFilename: htmlReportFilename,
-		debugLogger:        httpWriter,
-		enabledMap:         enabledMap,
-		SpecSummaryMap:     summaryMap{M: make(map[string]snapShotList)},
-		Suite: suite{
-			M:    make(map[string]*workflow),
-			Keys: []string{},
-		},
-	}
+	return enabledMap
 }
 
 func (reporter *HTMLReporter) SpecDidComplete(specSummary *types.SpecSummary) {
@@ -500,17 +657,27 @@ func (reporter *HTMLReporter) SpecDidComplete(specSummary *types.SpecSummary) {
 
 	snapshot := newSpecSnapshot(specSummary, reporter.Suite.Size)
 	reporter.save(snapshot)
+	if snapshot.IsWaived {
+		reporter.NumberOfWaivedSpecs++
+	}
+	if snapshot.IsBlocked {
+		reporter.NumberOfBlockedSpecs++
+	}
 	reporter.debugIndex = len(reporter.debugLogger.CapturedOutput)
 }
 
 func (reporter *HTMLReporter) save(snapshot *specSnapshot) {
-	suite := &reporter.Suite
+	reporter.Suite.add(snapshot, reporter.enabledMap)
+}
+
+// add files a snapshot under its workflow and category.
+func (suite *suite) add(snapshot *specSnapshot, enabledMap map[string]bool) {
 	ct := snapshot.ComponentTexts
 	suiteName, categoryName, specTitle := ct[flowIndex], ct[categoryIndex], ct[specIndex]
 	//make the map of categories
 	if _, ok := suite.M[suiteName]; !ok {
 		suite.M[suiteName] = &workflow{M: make(map[string]*category), Keys: []string{},
-			IsEnabled: reporter.enabledMap[suiteName]}
+			IsEnabled: enabledMap[suiteName]}
 		suite.Keys = append(suite.Keys, suiteName)
 	}
 	//make the map of snapshots
@@ -533,68 +700,44 @@ func (reporter *HTMLReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
 	reporter.PercentSkipped = getPercent(summary.NumberOfSkippedSpecs, summary.NumberOfTotalSpecs)
 	reporter.PercentFailed = getPercent(summary.NumberOfFailedSpecs, summary.NumberOfTotalSpecs)
 	reporter.SuiteSummary = summary
+	reporter.Benchmarks = benchmarks.list()
+	reporter.LegacyHeaders = legacyHeaders.list()
 	reporter.AllPassed = summary.NumberOfPassedSpecs == summary.NumberOfTotalSpecs
 	reporter.AllFailed = summary.NumberOfFailedSpecs == summary.NumberOfTotalSpecs
-	reporter.AllSkipped = summary.NumberOfSkippedSpecs == summary.NumberOfTotalSpecs
+	reporter.NumberOfSkippedSpecs = summary.NumberOfSkippedSpecs - reporter.NumberOfWaivedSpecs - reporter.NumberOfBlockedSpecs
+	reporter.AllSkipped = reporter.NumberOfSkippedSpecs == summary.NumberOfTotalSpecs
 
 	t, err := template.New("report").Parse(htmlTemplate)
 	if err != nil {
-		log.Fatal(err)
+		reportError(err)
+		return
 	}
 
 	htmlReportFilenameAbsPath, err := filepath.Abs(reporter.htmlReportFilename)
 	if err != nil {
-		log.Fatal(err)
+		reportError(err)
+		return
 	}
 
 	htmlReportFile, err := os.Create(htmlReportFilenameAbsPath)
 	if err != nil {
-		log.Fatal(err)
+		reportError(err)
+		return
 	}
 	defer htmlReportFile.Close()
 
 	err = t.ExecuteTemplate(htmlReportFile, "report", &reporter)
 	if err != nil {
-		log.Fatal(err)
+		reportError(err)
+		return
 	}
 
 	fmt.Printf("HTML report was created: %s", htmlReportFilenameAbsPath)
 }
 
-//unused by HTML reporter
+// unused by HTML reporter
 func (reporter *HTMLReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
-	varsToCheck := []string{
-		envVarRootURL,
-		envVarNamespace,
-		envVarUsername,
-		envVarPassword,
-		envVarDebug,
-		envVarPull,
-		envVarPush,
-		envVarContentDiscovery,
-		envVarContentManagement,
-		envVarPushEmptyLayer,
-		envVarBlobDigest,
-		envVarManifestDigest,
-		envVarTagName,
-		envVarTagList,
-		envVarHideSkippedWorkflows,
-		envVarAuthScope,
-		envVarCrossmountNamespace,
-	}
-	for _, v := range varsToCheck {
-		var replacement string
-		if envVar := os.Getenv(v); envVar != "" {
-			replacement = envVar
-			if strings.Contains(v, "PASSWORD") || strings.Contains(v, "USERNAME") {
-				replacement = "*****"
-			}
-		} else {
-			continue
-		}
-		reporter.EnvironmentVariables = append(reporter.EnvironmentVariables,
-			fmt.Sprintf("%s=%s", v, replacement))
-	}
+	reporter.EnvironmentVariables = runEnvironment
 
 	reporter.startTime = time.Now()
 	reporter.StartTimeString = reporter.startTime.Format("Jan 2 15:04:05.000 -0700 MST")
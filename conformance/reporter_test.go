@@ -0,0 +1,57 @@
+package conformance
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bloodorangeio/reggie"
+)
+
+// The HTTP log is published and packaged with the reports, so it must not
+// carry the credentials of a run, even those of a token exchange.
+func TestHTTPLogRedaction(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"token": "token-secret", "access_token": "access-secret", "expires_in": 300}`))
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			w.Header().Set("Www-Authenticate", `Bearer realm="`+srv.URL+`/token",service="test",scope="repository:a:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"name": "a", "tags": ["latest"]}`))
+	}))
+	defer srv.Close()
+
+	saved := httpWriter
+	defer func() { httpWriter = saved }()
+	httpWriter = newHTTPDebugWriter(false)
+	c, err := reggie.NewClient(srv.URL, reggie.WithDefaultName("a"), reggie.WithDebug(true),
+		reggie.WithUsernamePassword("user", "password-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetLogger(newHTTPDebugLogger(httpWriter))
+	resp, err := c.Do(c.NewRequest(reggie.GET, "/v2/<name>/tags/list").SetHeader("Cookie", "session=cookie-secret"))
+	if err != nil || resp.StatusCode() != http.StatusOK {
+		t.Fatalf("%v, %v", resp, err)
+	}
+
+	log := strings.Join(httpWriter.CapturedOutput, "\n")
+	for _, secret := range []string{"password-secret", "dXNlcjpwYXNzd29yZC1zZWNyZXQ=", "token-secret", "access-secret", "cookie-secret"} {
+		if strings.Contains(log, secret) {
+			t.Errorf("the log contains %q:\n%s", secret, log)
+		}
+	}
+	for _, kept := range []string{"GET  /v2/a/tags/list", "Authorization: *****", "Set-Cookie: *****", "***** TOKEN RESPONSE *****", `"tags": [`} {
+		if !strings.Contains(log, kept) {
+			t.Errorf("the log does not contain %q:\n%s", kept, log)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/onsi/ginkgo/config"
+	"github.com/onsi/ginkgo/types"
+)
+
+const (
+	statusPassed  = "passed"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
+	statusWaived  = "waived"
+	statusBlocked = "blocked"
+)
+
+type (
+	// SpecResult is the outcome of a single spec, as written to the JSON
+	// results file and read back when comparing two runs.
+	SpecResult struct {
+		ID      string  `json:"id,omitempty"`
+		Name    string  `json:"name"`
+		Status  string  `json:"status"`
+		Message string  `json:"message,omitempty"`
+		Seconds float64 `json:"seconds"`
+	}
+
+	// Results are the outcome of a run. Namespace is the repository the run
+	// pushed to, and TagPrefix the prefix of its tags, which differ from the
+	// configuration in isolated runs and are needed to sweep them.
+	Results struct {
+		Version   string       `json:"version"`
+		Namespace string       `json:"namespace,omitempty"`
+		TagPrefix string       `json:"tagPrefix,omitempty"`
+		Specs     []SpecResult `json:"specs"`
+	}
+
+	ResultsReporter struct {
+		resultsReportFilename string
+		results               Results
+	}
+
+	// junitReport is the subset of a JUnit report needed to compare runs. The
+	// root element is either a single testsuite or a testsuites element.
+	junitReport struct {
+		Cases  []junitCase  `xml:"testcase"`
+		Suites []junitSuite `xml:"testsuite"`
+	}
+
+	junitSuite struct {
+		Cases []junitCase `xml:"testcase"`
+	}
+
+	junitCase struct {
+		Name    string        `xml:"name,attr"`
+		Time    float64       `xml:"time,attr"`
+		Failure *junitMessage `xml:"failure"`
+		Error   *junitMessage `xml:"error"`
+		Skipped *junitMessage `xml:"skipped"`
+	}
+
+	junitMessage struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// Failed returns the specs which failed. Waived failures are not included.
+func (r *Results) Failed() []SpecResult {
+	var failed []SpecResult
+	for _, spec := range r.Specs {
+		if spec.Status == statusFailed {
+			failed = append(failed, spec)
+		}
+	}
+	return failed
+}
+
+func newResultsReporter(resultsReportFilename string) *ResultsReporter {
+	return &ResultsReporter{resultsReportFilename: resultsReportFilename, results: Results{Version: Version}}
+}
+
+func (reporter *ResultsReporter) SpecDidComplete(specSummary *types.SpecSummary) {
+	snapshot := newSpecSnapshot(specSummary, 0)
+	result := SpecResult{
+		ID:      snapshot.SpecID,
+		Name:    strings.Join(specSummary.ComponentTexts[1:], " "),
+		Seconds: specSummary.RunTime.Seconds(),
+	}
+	switch {
+	case snapshot.IsWaived:
+		result.Status = statusWaived
+		result.Message = strings.TrimPrefix(specSummary.Failure.Message, waivedPrefix)
+	case snapshot.IsBlocked:
+		result.Status = statusBlocked
+		result.Message = specSummary.Failure.Message
+	case specSummary.Skipped():
+		result.Status = statusSkipped
+		result.Message = specSummary.Failure.Message
+	case specSummary.Passed():
+		result.Status = statusPassed
+	default:
+		result.Status = statusFailed
+		result.Message = specSummary.Failure.Location.String() + "\n" + specSummary.Failure.Message
+		if specSummary.Failure.ForwardedPanic != "" {
+			result.Message += ": " + specSummary.Failure.ForwardedPanic
+		}
+	}
+	reporter.results.Specs = append(reporter.results.Specs, result)
+}
+
+func (reporter *ResultsReporter) SpecSuiteDidEnd(summary *types.SuiteSummary) {
+	b := new(bytes.Buffer)
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(reporter.results); err != nil {
+		reportError(err)
+		return
+	}
+
+	resultsReportFilenameAbsPath, err := filepath.Abs(reporter.resultsReportFilename)
+	if err != nil {
+		reportError(err)
+		return
+	}
+
+	if err := ioutil.WriteFile(resultsReportFilenameAbsPath, b.Bytes(), 0644); err != nil {
+		reportError(err)
+		return
+	}
+
+	fmt.Printf("Results file was created: %s\n", resultsReportFilenameAbsPath)
+}
+
+func (reporter *ResultsReporter) SpecSuiteWillBegin(config config.GinkgoConfigType, summary *types.SuiteSummary) {
+}
+
+func (reporter *ResultsReporter) BeforeSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+func (reporter *ResultsReporter) SpecWillRun(specSummary *types.SpecSummary) {
+}
+
+func (reporter *ResultsReporter) AfterSuiteDidRun(setupSummary *types.SetupSummary) {
+}
+
+// readResults reads the spec results of a run from either a JSON results
+// file or a JUnit report.
+func readResults(filename string) ([]SpecResult, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("<")) {
+		var report junitReport
+		if err := xml.Unmarshal(b, &report); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		cases := report.Cases
+		for _, suite := range report.Suites {
+			cases = append(cases, suite.Cases...)
+		}
+		results := make([]SpecResult, len(cases))
+		for i, c := range cases {
+			results[i] = c.result()
+		}
+		return results, nil
+	}
+
+	var results Results
+	if err := json.Unmarshal(b, &results); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return results.Specs, nil
+}
+
+func (c junitCase) result() SpecResult {
+	result := SpecResult{Name: c.Name, Seconds: c.Time, Status: statusPassed}
+	switch {
+	case c.Failure != nil:
+		result.Status, result.Message = statusFailed, c.Failure.text()
+	case c.Error != nil:
+		result.Status, result.Message = statusFailed, c.Error.text()
+	case c.Skipped != nil:
+		result.Status, result.Message = statusSkipped, c.Skipped.text()
+		// the JUnit report puts the location of the skip before its message
+		if strings.Contains(result.Message, "\n"+waivedPrefix) {
+			result.Status = statusWaived
+		} else if strings.Contains(result.Message, "\n"+blockedPrefix) {
+			result.Status = statusBlocked
+		}
+	}
+	return result
+}
+
+func (m *junitMessage) text() string {
+	if text := strings.TrimSpace(m.Text); text != "" {
+		return text
+	}
+	return m.Message
+}
@@ -0,0 +1,124 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-resty/resty/v2"
+	g "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/globals"
+	"github.com/onsi/ginkgo/reporters"
+	. "github.com/onsi/gomega"
+)
+
+// runMu serializes runs, as the workflows share the package globals.
+var runMu sync.Mutex
+
+// reportErrors are the errors of the reporters of the current run. Ginkgo
+// gives reporters no way to return errors, so they are collected here and
+// returned by Run.
+var reportErrors []error
+
+// reportError records an error of a reporter, which then gives up.
+func reportError(err error) {
+	reportErrors = append(reportErrors, err)
+}
+
+// suiteT stands in for the *testing.T ginkgo expects; failures are
+// reported through the results instead.
+type suiteT struct{}
+
+func (t suiteT) Fail() {}
+
+// Run runs the workflows enabled in cfg against the registry at cfg.RootURL,
+// writes the reports to cfg.ReportDir, and returns the result of every spec.
+// The configuration is checked before anything is sent to the registry.
+//
+// Specs which have not started when ctx is done are skipped, and requests in
+// flight are cancelled; the results so far are returned along with
+// ctx.Err(). If a report cannot be written or published, the results are
+// returned along with the error. Run may be called again once it has
+// returned; concurrent calls wait for each other.
+func Run(ctx context.Context, cfg Config) (*Results, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	runMu.Lock()
+	defer runMu.Unlock()
+	// ginkgo keeps the specs of a suite, and whether it has run, in global
+	// state, so the suite could only be run once per process without
+	// starting from an empty one
+	globals.Reset()
+	if err := setup(cfg); err != nil {
+		return nil, err
+	}
+
+	withContext := func(c *resty.Client, r *resty.Request) error {
+		r.SetContext(ctx)
+		return nil
+	}
+	client.OnBeforeRequest(withContext)
+	if upstreamClient != nil {
+		upstreamClient.OnBeforeRequest(withContext)
+	}
+
+	g.Describe(suiteDescription, func() {
+		g.BeforeEach(func() {
+			if ctx.Err() != nil {
+				g.Skip(ctx.Err().Error())
+			}
+		})
+		g.BeforeEach(SkipIfNotSelected)
+
+		test01Pull()
+		test02Push()
+		test03ContentDiscovery()
+		test04ContentManagement()
+		test05Benchmark()
+		test06TagMutability()
+		test07Artifacts()
+		test08Referrers()
+		test09DockerSchema2()
+		test10Mirror()
+	})
+
+	// remove anything the workflows created, even if their teardown
+	// specs did not get to run
+	g.AfterSuite(func() {
+		tracker.unwind()
+	})
+
+	RegisterFailHandler(g.Fail)
+	results := newResultsReporter(reportResultsFilename)
+	results.results.Namespace, results.results.TagPrefix = client.Config.DefaultName, tagPrefix
+	suiteReporters := []g.Reporter{newHTMLReporter(reportHTMLFilename), reporters.NewJUnitReporter(reportJUnitFilename),
+		newMarkdownReporter(reportMarkdownFilename, false), newBenchmarkReporter(reportBenchmarkFilename), results}
+	if r := newGitHubActionsReporter(); r != nil {
+		suiteReporters = append(suiteReporters, r)
+	}
+	// the bundle packages the files written by the other reporters, and is
+	// published along with them
+	if bundleFilename != "" {
+		suiteReporters = append(suiteReporters, newBundleReporter(bundleFilename, bundleSigningKeyFile, results))
+	}
+	if resultsNamespace != "" {
+		suiteReporters = append(suiteReporters, newPublishReporter(resultsNamespace, results))
+	}
+	reportErrors = nil
+	g.RunSpecsWithDefaultAndCustomReporters(suiteT{}, suiteDescription, suiteReporters)
+
+	if len(reportErrors) > 0 {
+		var messages []string
+		for _, err := range reportErrors {
+			messages = append(messages, err.Error())
+		}
+		err := fmt.Errorf("writing reports: %s", strings.Join(messages, "; "))
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w; %v", ctx.Err(), err)
+		}
+		return &results.results, err
+	}
+	return &results.results, ctx.Err()
+}
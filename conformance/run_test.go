@@ -0,0 +1,113 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// skipIfConformanceRun skips tests of Run when the binary is run against a
+// registry, so that only the suite itself reports to the user.
+func skipIfConformanceRun(t *testing.T) {
+	if os.Getenv(envVarRootURL) != "" {
+		t.Skipf("%s is set", envVarRootURL)
+	}
+}
+
+// The suite can be run more than once in a process, here against a registry
+// served in-process over TLS.
+func TestRunTwice(t *testing.T) {
+	skipIfConformanceRun(t)
+	srv := httptest.NewTLSServer(newFakeRegistry())
+	defer srv.Close()
+	cfg := Config{
+		RootURL:   srv.URL,
+		Namespace: "conformance/test",
+		Workflows: WorkflowPull,
+		ReportDir: t.TempDir(),
+		Transport: srv.Client().Transport,
+	}
+
+	var specs []int
+	for i := 0; i < 2; i++ {
+		results, err := Run(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+		passed := 0
+		for _, r := range results.Specs {
+			if r.Status == statusPassed {
+				passed++
+			}
+		}
+		if passed == 0 {
+			t.Errorf("run %d: no spec passed", i)
+		}
+		specs = append(specs, len(results.Specs))
+	}
+	if specs[0] == 0 || specs[0] != specs[1] {
+		t.Errorf("the runs had %v specs", specs)
+	}
+}
+
+func TestRunTransport(t *testing.T) {
+	skipIfConformanceRun(t)
+	srv := httptest.NewTLSServer(newFakeRegistry())
+	defer srv.Close()
+	cfg := Config{RootURL: srv.URL, Namespace: "conformance/test", Workflows: WorkflowPull, ReportDir: t.TempDir()}
+
+	// without the transport of the server, its certificate is not trusted
+	if _, err := Run(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "TLS") {
+		t.Errorf("without a transport: %v", err)
+	}
+
+	cfg.Transport = srv.Client().Transport
+	cfg.TLSCAFile = "ca.pem"
+	if _, err := Run(context.Background(), cfg); err == nil {
+		t.Error("a transport was accepted with a CA file")
+	}
+}
+
+// Errors of the reporters are returned rather than ending the process, and
+// a signing key which cannot be used is refused before the run.
+func TestRunReportErrors(t *testing.T) {
+	skipIfConformanceRun(t)
+	srv := httptest.NewTLSServer(newFakeRegistry())
+	defer srv.Close()
+	cfg := Config{
+		RootURL:   srv.URL,
+		Namespace: "conformance/test",
+		Workflows: WorkflowPull,
+		ReportDir: filepath.Join(t.TempDir(), "missing"),
+		Transport: srv.Client().Transport,
+	}
+
+	results, err := Run(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), "writing reports") || results == nil || len(results.Specs) == 0 {
+		t.Errorf("with a missing report directory: %v", err)
+	}
+
+	// publishing fails as well once the run is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cfg.ReportDir = t.TempDir()
+	cfg.ResultsNamespace = "conformance/results"
+	if _, err := Run(ctx, cfg); !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "publishing results") {
+		t.Errorf("with a cancelled run: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.ResultsNamespace = ""
+	cfg.Bundle = filepath.Join(t.TempDir(), "bundle.tar.gz")
+	cfg.BundleSigningKey = keyFile
+	if _, err := Run(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), envVarBundleSigningKey) {
+		t.Errorf("with an invalid signing key: %v", err)
+	}
+}
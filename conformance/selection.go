@@ -0,0 +1,173 @@
+package conformance
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	g "github.com/onsi/ginkgo"
+)
+
+const (
+	waivedPrefix = "waived: "
+)
+
+type (
+	// specSelector decides which individual specs run, based on patterns
+	// matched against each spec's stable ID.
+	specSelector struct {
+		include []*regexp.Regexp
+		exclude []*regexp.Regexp
+		waivers []waiver
+	}
+
+	// waiver is an expected failure, read from a waivers file, that is
+	// reported with its reason instead of being run.
+	waiver struct {
+		pattern *regexp.Regexp
+		reason  string
+	}
+)
+
+var nonAlphanumeric = regexp.MustCompile("[^a-z0-9]+")
+
+// specID builds the stable ID of a spec from its workflow, category and
+// title, e.g. "push/blob-upload-chunked/put-request-with-final-chunk-should-return-201".
+func specID(workflow, category, title string) string {
+	parts := []string{workflow, category, title}
+	for i, p := range parts {
+		parts[i] = strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(p), "-"), "-")
+	}
+	return strings.Join(parts, "/")
+}
+
+// newSpecSelector builds a selector from comma-separated include and
+// exclude patterns and an optional waivers file.
+func newSpecSelector(include, exclude, waiversFile string) (*specSelector, error) {
+	var err error
+	s := &specSelector{}
+	if s.include, err = compileSpecPatterns(include); err != nil {
+		return nil, err
+	}
+	if s.exclude, err = compileSpecPatterns(exclude); err != nil {
+		return nil, err
+	}
+	if waiversFile != "" {
+		if s.waivers, err = readWaivers(waiversFile); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func compileSpecPatterns(list string) ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := compileSpecPattern(p)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// compileSpecPattern anchors a pattern so that it must match a whole spec
+// ID; a plain spec ID therefore only selects that spec.
+func compileSpecPattern(p string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile("^(?:" + p + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec pattern %q: %v", p, err)
+	}
+	return re, nil
+}
+
+// readWaivers parses a waivers file. Each non-empty line that is not a
+// comment holds a spec ID or pattern, followed by whitespace and the reason
+// the spec is expected to fail.
+func readWaivers(filename string) ([]waiver, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var waivers []waiver
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		re, err := compileSpecPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, n, err)
+		}
+		reason := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		if reason == "" {
+			return nil, fmt.Errorf("%s:%d: waiver for %q has no reason", filename, n, fields[0])
+		}
+		waivers = append(waivers, waiver{pattern: re, reason: reason})
+	}
+	return waivers, scanner.Err()
+}
+
+// waiverReason returns why the spec is waived, if it is.
+func (s *specSelector) waiverReason(id string) (string, bool) {
+	for _, w := range s.waivers {
+		if w.pattern.MatchString(id) {
+			return w.reason, true
+		}
+	}
+	for _, re := range s.exclude {
+		if re.MatchString(id) {
+			return fmt.Sprintf("excluded by %s", envVarExcludeSpecs), true
+		}
+	}
+	return "", false
+}
+
+// isSelected reports whether the spec matches the include patterns, if any
+// were given.
+func (s *specSelector) isSelected(id string) bool {
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, re := range s.include {
+		if re.MatchString(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipIfNotSelected skips the current spec if it was not included, or if it
+// was excluded or waived, in which case it is reported as waived.
+func SkipIfNotSelected() {
+	texts := g.CurrentGinkgoTestDescription().ComponentTexts
+	// the top-level container is already stripped from these texts
+	if len(texts) <= specIndex-1 {
+		return
+	}
+	// leave specs of disabled workflows to SkipIfDisabled
+	if test, ok := workflowTests[texts[flowIndex-1]]; ok && userDisabled(test) {
+		return
+	}
+	id := specID(texts[flowIndex-1], texts[categoryIndex-1], texts[specIndex-1])
+	if !selector.isSelected(id) {
+		g.Skip(fmt.Sprintf("you have skipped this test; it does not match %s", envVarIncludeSpecs))
+	}
+	if reason, waived := selector.waiverReason(id); waived {
+		g.Skip(waivedPrefix + reason)
+	}
+}
+
+func isWaived(message string) bool {
+	return strings.HasPrefix(message, waivedPrefix)
+}
@@ -0,0 +1,162 @@
+package conformance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSpecID(t *testing.T) {
+	for _, tc := range []struct {
+		workflow, category, title string
+		want                      string
+	}{
+		{"Push", "Blob Upload Chunked", "PUT request with final chunk should return 201",
+			"push/blob-upload-chunked/put-request-with-final-chunk-should-return-201"},
+		{"Pull", "Setup", "Populate registry with test blob",
+			"pull/setup/populate-registry-with-test-blob"},
+		{"Pull", "Pull manifests", "GET /v2/<name>/manifests/<reference> should return 404",
+			"pull/pull-manifests/get-v2-name-manifests-reference-should-return-404"},
+		{"Push", "Manifest Upload", "  trailing punctuation... ",
+			"push/manifest-upload/trailing-punctuation"},
+	} {
+		if got := specID(tc.workflow, tc.category, tc.title); got != tc.want {
+			t.Errorf("specID(%q, %q, %q) = %q, want %q", tc.workflow, tc.category, tc.title, got, tc.want)
+		}
+	}
+}
+
+// Titles that only differ in case or punctuation share an ID, so a pattern
+// for one of them selects the other too.
+func TestSpecIDCollisions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b    string
+		collide bool
+	}{
+		{"GET nonexistent blob should return 404", "GET nonexistent blob should return 404.", true},
+		{"GET nonexistent blob should return 404", "get nonexistent-blob: should return 404", true},
+		{"PUT manifest (tagged) should return 201", "PUT manifest tagged should return 201", true},
+		{"GET nonexistent blob should return 404", "GET nonexistent blobs should return 404", false},
+		{"Delete tag should return 202", "Delete tag should return 2020", false},
+	} {
+		a, b := specID("Pull", "Pull blobs", tc.a), specID("Pull", "Pull blobs", tc.b)
+		if (a == b) != tc.collide {
+			t.Errorf("%q and %q: IDs %q and %q, want collision %v", tc.a, tc.b, a, b, tc.collide)
+		}
+	}
+
+	// the slug of each part is separate, so a title cannot collide with the
+	// category it is in
+	if specID("Pull", "Pull blobs", "x") == specID("Pull", "Pull", "blobs x") {
+		t.Error("spec IDs of different categories collide")
+	}
+}
+
+func TestReadWaivers(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    map[string]string // spec ID -> reason, or "" if not waived
+		wantErr bool
+	}{
+		{
+			name: "comments and blank lines",
+			content: "# expected failures\n" +
+				"\n" +
+				"   \n" +
+				"  # indented comment\n" +
+				"push/blob-upload-chunked/out-of-order    not validated (issue #123)\n" +
+				"\t\n",
+			want: map[string]string{
+				"push/blob-upload-chunked/out-of-order": "not validated (issue #123)",
+				"pull/setup/populate":                   "",
+			},
+		},
+		{
+			name:    "pattern",
+			content: "content-management/blob-delete/.*\tgarbage collected\n",
+			want: map[string]string{
+				"content-management/blob-delete/delete-blob-should-return-202": "garbage collected",
+				"content-management/manifest-delete/delete-manifest":           "",
+			},
+		},
+		{
+			name:    "anchored",
+			content: "pull/setup/populate  partial ID\n",
+			want: map[string]string{
+				"pull/setup/populate-registry": "",
+				"x/pull/setup/populate":        "",
+				"pull/setup/populate":          "partial ID",
+			},
+		},
+		{
+			name:    "first waiver wins",
+			content: "push/.*  first\npush/a/b  second\n",
+			want:    map[string]string{"push/a/b": "first"},
+		},
+		{
+			name:    "no reason",
+			content: "# comment\npush/a/b\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			content: "push/(a  broken\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "waivers.txt")
+			if err := os.WriteFile(filename, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			s, err := newSpecSelector("", "", filename)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, want := range tc.want {
+				reason, waived := s.waiverReason(id)
+				if waived != (want != "") || reason != want {
+					t.Errorf("waiverReason(%q) = %q, %v, want %q", id, reason, waived, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectionPrecedence(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "waivers.txt")
+	if err := os.WriteFile(filename, []byte("push/a/waived  known failure\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newSpecSelector("push/.*", "push/a/.*", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		id       string
+		selected bool
+		reason   string // "" if neither waived nor excluded
+	}{
+		{"push/b/included", true, ""},
+		{"pull/a/not-included", false, ""},
+		// an exclude pattern wins over an include pattern
+		{"push/a/excluded", true, "excluded by " + envVarExcludeSpecs},
+		// and a waiver over an exclude pattern, for its reason
+		{"push/a/waived", true, "known failure"},
+	} {
+		if selected := s.isSelected(tc.id); selected != tc.selected {
+			t.Errorf("isSelected(%q) = %v, want %v", tc.id, selected, tc.selected)
+		}
+		if reason, _ := s.waiverReason(tc.id); reason != tc.reason {
+			t.Errorf("waiverReason(%q) = %q, want %q", tc.id, reason, tc.reason)
+		}
+	}
+}
@@ -6,14 +6,17 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"log"
 	"math/big"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/bloodorangeio/reggie"
 	"github.com/google/uuid"
 	g "github.com/onsi/ginkgo"
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
 	godigest "github.com/opencontainers/go-digest"
 	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
 )
@@ -29,6 +32,14 @@ type (
 		ContentLength string
 		Digest        string
 	}
+
+	// errorInfo is an entry of an error response, decoded through
+	// v1.ErrorInfo. Registries commonly send a JSON object as the detail,
+	// so it is kept as raw JSON rather than as a string.
+	errorInfo struct {
+		v1.ErrorInfo
+		Detail json.RawMessage `json:"detail"`
+	}
 )
 
 const (
@@ -36,6 +47,12 @@ const (
 	push
 	contentDiscovery
 	contentManagement
+	benchmark
+	tagMutability
+	artifacts
+	referrers
+	dockerSchema2
+	mirror
 
 	BLOB_UNKNOWN = iota
 	BLOB_UPLOAD_INVALID
@@ -71,14 +88,64 @@ const (
 	envVarAuthScope                 = "OCI_AUTH_SCOPE"
 	envVarDeleteManifestBeforeBlobs = "OCI_DELETE_MANIFEST_BEFORE_BLOBS"
 	envVarCrossmountNamespace       = "OCI_CROSSMOUNT_NAMESPACE"
-
-	emptyLayerTestTag = "emptylayer"
-	testTagName       = "tagtest0"
+	envVarCrossmountUnreadable      = "OCI_CROSSMOUNT_UNREADABLE_NAMESPACE"
+	envVarCrossmountUnreadableBlob  = "OCI_CROSSMOUNT_UNREADABLE_DIGEST"
+	envVarSweep                     = "OCI_SWEEP"
+	envVarSweepTagPrefix            = "OCI_SWEEP_TAG_PREFIX"
+	envVarIncludeSpecs              = "OCI_INCLUDE_SPECS"
+	envVarExcludeSpecs              = "OCI_EXCLUDE_SPECS"
+	envVarWaiversFile               = "OCI_WAIVERS_FILE"
+	envVarBenchmark                 = "OCI_TEST_BENCHMARK"
+	envVarBenchmarkIterations       = "OCI_BENCHMARK_ITERATIONS"
+	envVarBenchmarkConcurrency      = "OCI_BENCHMARK_CONCURRENCY"
+	envVarBenchmarkBlobSizes        = "OCI_BENCHMARK_BLOB_SIZES"
+	envVarBenchmarkChunkSize        = "OCI_BENCHMARK_CHUNK_SIZE"
+	envVarTagMutability             = "OCI_TEST_TAG_MUTABILITY"
+	envVarArtifacts                 = "OCI_TEST_ARTIFACTS"
+	envVarReferrers                 = "OCI_TEST_REFERRERS"
+	envVarDockerSchema2             = "OCI_TEST_DOCKER_SCHEMA2"
+	envVarTLSCAFile                 = "OCI_TLS_CA_FILE"
+	envVarTLSCertFile               = "OCI_TLS_CERT_FILE"
+	envVarTLSKeyFile                = "OCI_TLS_KEY_FILE"
+	envVarPlainHTTP                 = "OCI_PLAIN_HTTP"
+	envVarMirror                    = "OCI_TEST_MIRROR"
+	envVarMirrorUpstreamURL         = "OCI_MIRROR_UPSTREAM_URL"
+	envVarMirrorUpstreamNamespace   = "OCI_MIRROR_UPSTREAM_NAMESPACE"
+	envVarMirrorUpstreamUsername    = "OCI_MIRROR_UPSTREAM_USERNAME"
+	envVarMirrorUpstreamPassword    = "OCI_MIRROR_UPSTREAM_PASSWORD"
+	envVarFuzz                      = "OCI_FUZZ"
+	envVarFuzzSeed                  = "OCI_FUZZ_SEED"
+	envVarFuzzSequences             = "OCI_FUZZ_SEQUENCES"
+	envVarFuzzLength                = "OCI_FUZZ_LENGTH"
+	envVarDiffBaseline              = "OCI_DIFF_BASELINE"
+	envVarDiffResults               = "OCI_DIFF_RESULTS"
+	envVarDiffTimingThreshold       = "OCI_DIFF_TIMING_THRESHOLD"
+	envVarBundle                    = "OCI_BUNDLE"
+	envVarBundleSigningKey          = "OCI_BUNDLE_SIGNING_KEY"
+	envVarBundleVerify              = "OCI_BUNDLE_VERIFY"
+	envVarBundlePublicKey           = "OCI_BUNDLE_PUBLIC_KEY"
+	envVarResultsNamespace          = "OCI_RESULTS_NAMESPACE"
+	envVarIsolate                   = "OCI_ISOLATE"
+
+	emptyLayerTestTag         = "emptylayer"
+	testTagName               = "tagtest0"
+	benchmarkTagName          = "benchmark"
+	mutableTagName            = "mutabletest"
+	artifactTagPrefix         = "artifact-"
+	dockerManifestTagName     = "docker-schema2"
+	dockerManifestListTagName = "docker-manifest-list"
+	mirrorTagName             = "mirrortest"
 
 	titlePull              = "Pull"
 	titlePush              = "Push"
 	titleContentDiscovery  = "Content Discovery"
 	titleContentManagement = "Content Management"
+	titleBenchmark         = "Benchmark"
+	titleTagMutability     = "Tag Mutability"
+	titleArtifacts         = "Artifacts"
+	titleReferrers         = "Referrers"
+	titleDockerSchema2     = "Docker Schema 2"
+	titleMirror            = "Mirror"
 
 	//	layerBase64String is a base64 encoding of a simple tarball, obtained like this:
 	//		$ echo 'you bothered to find out what was in here. Congratulations!' > test.txt
@@ -90,89 +157,198 @@ const (
 )
 
 var (
-	testMap = map[string]int{
-		envVarPull:              pull,
-		envVarPush:              push,
-		envVarContentDiscovery:  contentDiscovery,
-		envVarContentManagement: contentManagement,
-	}
-
-	testBlobA                 []byte
-	testBlobALength           string
-	testBlobADigest           string
-	testBlobB                 []byte
-	testBlobBDigest           string
-	testBlobBChunk1           []byte
-	testBlobBChunk1Length     string
-	testBlobBChunk2           []byte
-	testBlobBChunk2Length     string
-	testBlobBChunk1Range      string
-	testBlobBChunk2Range      string
-	client                    *reggie.Client
-	crossmountNamespace       string
-	dummyDigest               string
-	errorCodes                []string
-	invalidManifestContent    []byte
-	layerBlobData             []byte
-	layerBlobDigest           string
-	layerBlobContentLength    string
-	emptyLayerManifestContent []byte
-	nonexistentManifest       string
-	reportJUnitFilename       string
-	reportHTMLFilename        string
-	httpWriter                *httpDebugWriter
-	testsToRun                int
-	suiteDescription          string
-	runPullSetup              bool
-	runPushSetup              bool
-	runContentDiscoverySetup  bool
-	runContentManagementSetup bool
-	skipEmptyLayerTest        bool
-	deleteManifestBeforeBlobs bool
-	configs                   []TestBlob
-	manifests                 []TestBlob
-	Version                   = "unknown"
+	workflowTests = map[string]int{
+		titlePull:              pull,
+		titlePush:              push,
+		titleContentDiscovery:  contentDiscovery,
+		titleContentManagement: contentManagement,
+		titleBenchmark:         benchmark,
+		titleTagMutability:     tagMutability,
+		titleArtifacts:         artifacts,
+		titleReferrers:         referrers,
+		titleDockerSchema2:     dockerSchema2,
+		titleMirror:            mirror,
+	}
+
+	testBlobA                     []byte
+	testBlobALength               string
+	testBlobADigest               string
+	testBlobB                     []byte
+	testBlobBDigest               string
+	testBlobBChunk1               []byte
+	testBlobBChunk1Length         string
+	testBlobBChunk2               []byte
+	testBlobBChunk2Length         string
+	testBlobBChunk1Range          string
+	testBlobBChunk2Range          string
+	client                        *reggie.Client
+	upstreamClient                *reggie.Client
+	crossmountNamespace           string
+	crossmountUnreadableNamespace string
+	crossmountUnreadableDigest    string
+	nonexistentNamespace          string
+	dummyDigest                   string
+	errorCodes                    []string
+	invalidManifestContent        []byte
+	layerBlobData                 []byte
+	layerBlobDigest               string
+	layerBlobContentLength        string
+	emptyLayerManifestContent     []byte
+	nonexistentManifest           string
+	reportJUnitFilename           string
+	reportHTMLFilename            string
+	reportMarkdownFilename        string
+	reportBenchmarkFilename       string
+	reportResultsFilename         string
+	httpWriter                    *httpDebugWriter
+	runEnvironment                []string
+	testsToRun                    int
+	suiteDescription              string
+	runPullSetup                  bool
+	runPushSetup                  bool
+	runContentDiscoverySetup      bool
+	runContentManagementSetup     bool
+	skipEmptyLayerTest            bool
+	deleteManifestBeforeBlobs     bool
+	hideSkippedWorkflows          bool
+	existingTagName               string
+	existingTagList               []string
+	tagPrefix                     string
+	sweepMode                     bool
+	sweepTagPrefix                string
+	fuzzMode                      bool
+	fuzzSeed                      int64
+	fuzzSequences                 int
+	fuzzLength                    int
+	diffBaselineFilename          string
+	diffResultsFilename           string
+	diffTimingThreshold           int
+	bundleFilename                string
+	bundleSigningKeyFile          string
+	bundleVerifyFilename          string
+	bundlePublicKeyFile           string
+	resultsNamespace              string
+	tracker                       *resourceTracker
+	legacyHeaders                 *legacyHeaderChecker
+	selector                      *specSelector
+	benchmarks                    *benchmarkResults
+	benchmarkIterations           int
+	benchmarkConcurrency          int
+	benchmarkBlobSizes            []int64
+	benchmarkChunkSize            int64
+	configs                       []TestBlob
+	manifests                     []TestBlob
+	Version                       = "unknown"
 )
 
-func init() {
+// setup prepares the globals the workflows use for a run configured by cfg.
+func setup(cfg Config) error {
 	var err error
 
-	hostname := os.Getenv(envVarRootURL)
-	namespace := os.Getenv(envVarNamespace)
-	username := os.Getenv(envVarUsername)
-	password := os.Getenv(envVarPassword)
-	authScope := os.Getenv(envVarAuthScope)
-	crossmountNamespace = os.Getenv(envVarCrossmountNamespace)
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	namespace := cfg.Namespace
+	upstreamNamespace := cfg.MirrorUpstreamNamespace
+	if upstreamNamespace == "" {
+		upstreamNamespace = namespace
+	}
+	// in isolation mode, everything is pushed to a repository of its own,
+	// and tagged so that the tags of this run can be told apart
+	tagPrefix = ""
+	if cfg.Isolate {
+		runID := uuid.New().String()
+		namespace = fmt.Sprintf("%s/conformance-%s", namespace, runID)
+		upstreamNamespace = fmt.Sprintf("%s/conformance-%s", upstreamNamespace, runID)
+		tagPrefix = runID[:8] + "-"
+	}
+
+	crossmountNamespace = cfg.CrossmountNamespace
 	if len(crossmountNamespace) == 0 {
 		crossmountNamespace = fmt.Sprintf("conformance-%s", uuid.New())
 	}
+	crossmountUnreadableNamespace = cfg.CrossmountUnreadableNamespace
+	crossmountUnreadableDigest = cfg.CrossmountUnreadableDigest
+	nonexistentNamespace = fmt.Sprintf("conformance-%s", uuid.New())
 
-	debug, _ := strconv.ParseBool(os.Getenv(envVarDebug))
-
-	for envVar, enableTest := range testMap {
-		if varIsTrue, _ := strconv.ParseBool(os.Getenv(envVar)); varIsTrue {
-			testsToRun |= enableTest
-		}
+	hostname, err := normalizeRootURL(cfg.RootURL, cfg.PlainHTTP)
+	if err != nil {
+		return fmt.Errorf("%s: %v", envVarRootURL, err)
+	}
+	tlsConfig, err := newTLSConfig(cfg.TLSCAFile, cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("invalid TLS configuration: %v", err)
 	}
 
-	httpWriter = newHTTPDebugWriter(debug)
+	testsToRun = int(cfg.Workflows)
+	runEnvironment = cfg.environment()
+
+	httpWriter = newHTTPDebugWriter(cfg.Debug)
 	logger := newHTTPDebugLogger(httpWriter)
 	client, err = reggie.NewClient(hostname,
 		reggie.WithDefaultName(namespace),
-		reggie.WithUsernamePassword(username, password),
+		reggie.WithUsernamePassword(cfg.Username, cfg.Password),
 		reggie.WithDebug(true),
 		reggie.WithUserAgent("distribution-spec-conformance-tests"),
-		reggie.WithAuthScope(authScope))
+		reggie.WithAuthScope(cfg.AuthScope))
 	if err != nil {
-		panic(err)
+		return err
 	}
 
+	if cfg.Transport != nil {
+		client.SetTransport(cfg.Transport)
+	}
+	if tlsConfig != nil {
+		client.SetTLSClientConfig(tlsConfig)
+	}
+	if err := checkTLS(hostname, client.GetClient()); err != nil {
+		return err
+	}
 	client.SetLogger(logger)
 	client.SetCookieJar(nil)
 
+	// record everything the suite creates so it can be removed at the end
+	// of the run, even when a spec fails before its teardown
+	tracker = newResourceTracker()
+	client.OnAfterResponse(tracker.afterResponse(client))
+
+	// note which of the optional legacy Docker headers the registry sends
+	legacyHeaders = newLegacyHeaderChecker()
+	client.OnAfterResponse(legacyHeaders.afterResponse)
+
+	// in mirror mode, content is seeded into the upstream of the registry
+	upstreamClient = nil
+	if !userDisabled(mirror) {
+		upstreamURL, err := normalizeRootURL(cfg.MirrorUpstreamURL, cfg.PlainHTTP)
+		if err != nil {
+			return fmt.Errorf("%s: %v", envVarMirrorUpstreamURL, err)
+		}
+		upstreamClient, err = reggie.NewClient(upstreamURL,
+			reggie.WithDefaultName(upstreamNamespace),
+			reggie.WithUsernamePassword(cfg.MirrorUpstreamUsername, cfg.MirrorUpstreamPassword),
+			reggie.WithDebug(true),
+			reggie.WithUserAgent("distribution-spec-conformance-tests"))
+		if err != nil {
+			return err
+		}
+		if cfg.Transport != nil {
+			upstreamClient.SetTransport(cfg.Transport)
+		}
+		if tlsConfig != nil {
+			upstreamClient.SetTLSClientConfig(tlsConfig)
+		}
+		if err := checkTLS(upstreamURL, upstreamClient.GetClient()); err != nil {
+			return err
+		}
+		upstreamClient.SetLogger(logger)
+		upstreamClient.SetCookieJar(nil)
+		upstreamClient.OnAfterResponse(tracker.afterResponse(upstreamClient))
+	}
+
 	// create a unique config for each workflow category
+	configs = nil
 	for i := 0; i < 4; i++ {
-
 		// in order to get a unique blob digest, we create a new author
 		// field for the config on each run.
 		randomAuthor := randomString(16)
@@ -187,14 +363,14 @@ func init() {
 		}
 		configBlobContent, err := json.MarshalIndent(&config, "", "\t")
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		configBlobContentLength := strconv.Itoa(len(configBlobContent))
 		configBlobDigestRaw := godigest.FromBytes(configBlobContent)
 		configBlobDigest := configBlobDigestRaw.String()
-		if v := os.Getenv(envVarBlobDigest); v != "" {
-			configBlobDigest = v
+		if cfg.BlobDigest != "" {
+			configBlobDigest = cfg.BlobDigest
 		}
 
 		configs = append(configs, TestBlob{
@@ -206,7 +382,7 @@ func init() {
 
 	layerBlobData, err = base64.StdEncoding.DecodeString(layerBase64String)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	layerBlobDigestRaw := godigest.FromBytes(layerBlobData)
@@ -220,6 +396,7 @@ func init() {
 	}}
 
 	// create a unique manifest for each workflow category
+	manifests = nil
 	for i := 0; i < 4; i++ {
 		manifest := imagespec.Manifest{
 			Config: imagespec.Descriptor{
@@ -233,13 +410,13 @@ func init() {
 
 		manifestContent, err := json.MarshalIndent(&manifest, "", "\t")
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		manifestContentLength := strconv.Itoa(len(manifestContent))
 		manifestDigest := godigest.FromBytes(manifestContent).String()
-		if v := os.Getenv(envVarManifestDigest); v != "" {
-			manifestDigest = v
+		if cfg.ManifestDigest != "" {
+			manifestDigest = cfg.ManifestDigest
 		}
 
 		manifests = append(manifests, TestBlob{
@@ -262,7 +439,7 @@ func init() {
 
 	emptyLayerManifestContent, err = json.MarshalIndent(&emptyLayerManifest, "", "\t")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	nonexistentManifest = ".INVALID_MANIFEST_NAME"
@@ -308,22 +485,116 @@ func init() {
 	skipEmptyLayerTest = false
 	deleteManifestBeforeBlobs = false
 
-	if os.Getenv(envVarTagName) != "" &&
-		os.Getenv(envVarManifestDigest) != "" &&
-		os.Getenv(envVarBlobDigest) != "" {
+	if cfg.TagName != "" && cfg.ManifestDigest != "" && cfg.BlobDigest != "" {
 		runPullSetup = false
 	}
+	existingTagName = cfg.TagName
 
-	if os.Getenv(envVarTagList) != "" {
+	if len(cfg.TagList) > 0 {
 		runContentDiscoverySetup = false
 	}
+	existingTagList = cfg.TagList
+
+	skipEmptyLayerTest = cfg.SkipEmptyLayerPush
+	deleteManifestBeforeBlobs = cfg.DeleteManifestBeforeBlobs
+	hideSkippedWorkflows = cfg.HideSkippedWorkflows
+
+	selector, err = newSpecSelector(cfg.IncludeSpecs, cfg.ExcludeSpecs, cfg.WaiversFile)
+	if err != nil {
+		return err
+	}
+
+	benchmarks = &benchmarkResults{}
+	benchmarkIterations = cfg.BenchmarkIterations
+	if benchmarkIterations == 0 {
+		benchmarkIterations = defaultBenchmarkIterations
+	}
+	benchmarkConcurrency = cfg.BenchmarkConcurrency
+	if benchmarkConcurrency == 0 {
+		benchmarkConcurrency = defaultBenchmarkConcurrency
+	}
+	benchmarkBlobSizes = cfg.BenchmarkBlobSizes
+	if len(benchmarkBlobSizes) == 0 {
+		if benchmarkBlobSizes, err = parseSizes(defaultBenchmarkBlobSizes); err != nil {
+			return err
+		}
+	}
+	benchmarkChunkSize = cfg.BenchmarkChunkSize
+	if benchmarkChunkSize == 0 {
+		benchmarkChunkSize = defaultBenchmarkChunkSize
+	}
 
-	skipEmptyLayerTest, _ = strconv.ParseBool(os.Getenv(envVarPushEmptyLayer))
-	deleteManifestBeforeBlobs, _ = strconv.ParseBool(os.Getenv(envVarDeleteManifestBeforeBlobs))
+	if !userDisabled(benchmark) {
+		// avoid keeping every downloaded blob in the captured output
+		client.SetDebugBodyLimit(1024)
+	}
+
+	bundleFilename = cfg.Bundle
+	bundleSigningKeyFile = cfg.BundleSigningKey
+	resultsNamespace = cfg.ResultsNamespace
 
-	reportJUnitFilename = "junit.xml"
-	reportHTMLFilename = "report.html"
+	reportJUnitFilename = filepath.Join(cfg.ReportDir, "junit.xml")
+	reportHTMLFilename = filepath.Join(cfg.ReportDir, "report.html")
+	reportMarkdownFilename = filepath.Join(cfg.ReportDir, "report.md")
+	reportBenchmarkFilename = filepath.Join(cfg.ReportDir, "benchmark.json")
+	reportResultsFilename = filepath.Join(cfg.ReportDir, "results.json")
 	suiteDescription = "OCI Distribution Conformance Tests"
+	return nil
+}
+
+// readModes reads the environment variables which run the binary in one of
+// its other modes instead of the workflows.
+func readModes() error {
+	var err error
+
+	sweepMode, _ = strconv.ParseBool(getEnv(envVarSweep))
+	sweepTagPrefix = getEnv(envVarSweepTagPrefix)
+
+	fuzzMode, _ = strconv.ParseBool(getEnv(envVarFuzz))
+	fuzzSeed = time.Now().UnixNano()
+	if v := getEnv(envVarFuzzSeed); v != "" {
+		if fuzzSeed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return fmt.Errorf("invalid %s: %q", envVarFuzzSeed, v)
+		}
+	}
+	fuzzSequences = defaultFuzzSequences
+	if v := getEnv(envVarFuzzSequences); v != "" {
+		if fuzzSequences, err = strconv.Atoi(v); err != nil || fuzzSequences < 1 {
+			return fmt.Errorf("invalid %s: %q", envVarFuzzSequences, v)
+		}
+	}
+	fuzzLength = defaultFuzzLength
+	if v := getEnv(envVarFuzzLength); v != "" {
+		if fuzzLength, err = strconv.Atoi(v); err != nil || fuzzLength < 1 {
+			return fmt.Errorf("invalid %s: %q", envVarFuzzLength, v)
+		}
+	}
+
+	diffBaselineFilename = getEnv(envVarDiffBaseline)
+	diffResultsFilename = getEnv(envVarDiffResults)
+	if diffResultsFilename == "" {
+		diffResultsFilename = "results.json"
+	}
+	diffTimingThreshold = defaultDiffTimingThreshold
+	if v := getEnv(envVarDiffTimingThreshold); v != "" {
+		if diffTimingThreshold, err = strconv.Atoi(strings.TrimSuffix(v, "%")); err != nil || diffTimingThreshold < 0 {
+			return fmt.Errorf("invalid %s: %q", envVarDiffTimingThreshold, v)
+		}
+	}
+
+	bundleVerifyFilename = getEnv(envVarBundleVerify)
+	bundlePublicKeyFile = getEnv(envVarBundlePublicKey)
+	return nil
+}
+
+// getEnv returns the value of an OCI_* environment variable. When it is not
+// set, the matching GitHub Action input is used instead, e.g. INPUT_ROOT_URL
+// for OCI_ROOT_URL.
+func getEnv(name string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return os.Getenv("INPUT_" + strings.TrimPrefix(name, "OCI_"))
 }
 
 func SkipIfDisabled(test int) {
@@ -348,8 +619,8 @@ func RunOnlyIfNot(v bool) {
 func generateSkipReport() string {
 	buf := new(bytes.Buffer)
 	fmt.Fprintf(buf, "you have skipped this test; if this is an error, check your environment variable settings:\n")
-	for k := range testMap {
-		fmt.Fprintf(buf, "\t%s=%s\n", k, os.Getenv(k))
+	for k := range workflowEnvVars {
+		fmt.Fprintf(buf, "\t%s=%s\n", k, getEnv(k))
 	}
 	return buf.String()
 }
@@ -376,13 +647,43 @@ func getTagNameFromResponse(lastResponse *reggie.Response) (tagName string) {
 		err := json.Unmarshal(jsonData, tl)
 		if err == nil && len(tl.Tags) > 0 {
 			tagName = tl.Tags[0]
+			if own := ownTags(tl.Tags); len(own) > 0 {
+				tagName = own[0]
+			}
 		}
 	}
 
 	return
 }
 
+// runTag returns the tag which the workflows push as name during this run.
+func runTag(name string) string {
+	return tagPrefix + name
+}
+
+// ownTags returns the tags pushed by this run, so that tags pushed by other
+// runs to the same repository are not counted.
+func ownTags(tags []string) []string {
+	var own []string
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, tagPrefix) {
+			own = append(own, tag)
+		}
+	}
+	return own
+}
+
 // Adapted from https://gist.github.com/dopey/c69559607800d2f2f90b1b1ed4e550fb
+// randomTag returns a new tag for content that is only pushed once. Tags
+// match [a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}, so they must not start with '-'.
+func randomTag() string {
+	tag := randomString(16)
+	for tag[0] == '-' {
+		tag = randomString(16)
+	}
+	return runTag(tag)
+}
+
 func randomString(n int) string {
 	const letters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-"
 	ret := make([]byte, n)
@@ -395,3 +696,131 @@ func randomString(n int) string {
 	}
 	return string(ret)
 }
+
+// checkStatus returns an error if resp does not have one of the expected
+// status codes.
+func checkStatus(resp *reggie.Response, err error, expected ...int) error {
+	if err != nil {
+		return err
+	}
+	for _, code := range expected {
+		if resp.StatusCode() == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s: unexpected status %d", resp.Request.Method, resp.Request.URL, resp.StatusCode())
+}
+
+func newTestBlob(content []byte) *TestBlob {
+	return &TestBlob{
+		Content:       content,
+		ContentLength: strconv.Itoa(len(content)),
+		Digest:        godigest.FromBytes(content).String(),
+	}
+}
+
+// randomBlob returns size bytes of random content, so that every upload
+// is of a blob the registry has not seen before.
+func randomBlob(size int64) []byte {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// uploadBlobMonolithic uploads a blob with a POST followed by a single PUT.
+func uploadBlobMonolithic(blob *TestBlob) error {
+	return uploadBlobMonolithicWith(client, blob)
+}
+
+// uploadBlobMonolithicWith uploads a blob to the registry of the given client.
+func uploadBlobMonolithicWith(client *reggie.Client, blob *TestBlob) error {
+	req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/")
+	resp, err := client.Do(req)
+	if err := checkStatus(resp, err, 202); err != nil {
+		return err
+	}
+	req = client.NewRequest(reggie.PUT, resp.GetRelativeLocation(), reggie.WithRetryCallback(resetBody(blob.Content))).
+		SetQueryParam("digest", blob.Digest).
+		SetHeader("Content-Type", "application/octet-stream").
+		SetHeader("Content-Length", blob.ContentLength).
+		SetBody(bytes.NewReader(blob.Content))
+	resp, err = client.Do(req)
+	return checkStatus(resp, err, 201)
+}
+
+// uploadBlobChunked uploads a blob with a POST, a PATCH per chunk and a
+// closing PUT.
+func uploadBlobChunked(blob *TestBlob, chunkSize int64) error {
+	content := blob.Content
+	req := client.NewRequest(reggie.POST, "/v2/<name>/blobs/uploads/").
+		SetHeader("Content-Length", "0")
+	resp, err := client.Do(req)
+	if err := checkStatus(resp, err, 202); err != nil {
+		return err
+	}
+	location := resp.GetRelativeLocation()
+	for offset := int64(0); offset < int64(len(content)); offset += chunkSize {
+		end := offset + chunkSize
+		if end > int64(len(content)) {
+			end = int64(len(content))
+		}
+		chunk := content[offset:end]
+		req = client.NewRequest(reggie.PATCH, location, reggie.WithRetryCallback(resetBody(chunk))).
+			SetHeader("Content-Type", "application/octet-stream").
+			SetHeader("Content-Length", strconv.Itoa(len(chunk))).
+			SetHeader("Content-Range", fmt.Sprintf("%d-%d", offset, end-1)).
+			SetBody(bytes.NewReader(chunk))
+		resp, err = client.Do(req)
+		if err := checkStatus(resp, err, 202); err != nil {
+			return err
+		}
+		location = resp.GetRelativeLocation()
+	}
+	req = client.NewRequest(reggie.PUT, location).
+		SetQueryParam("digest", blob.Digest).
+		SetHeader("Content-Length", "0")
+	resp, err = client.Do(req)
+	return checkStatus(resp, err, 201)
+}
+
+// resetBody returns a retry callback which rewinds a request body that is
+// sent as a reader, so that it can be sent again after authenticating.
+func resetBody(content []byte) reggie.RetryCallbackFunc {
+	return func(req *reggie.Request) error {
+		req.SetBody(bytes.NewReader(content))
+		return nil
+	}
+}
+
+// newManifest returns the content of an image manifest with the given
+// config and layers, which need not exist in the registry.
+func newManifest(config imagespec.Descriptor, layers ...imagespec.Descriptor) []byte {
+	manifest := imagespec.Manifest{
+		Config: config,
+		Layers: append([]imagespec.Descriptor{}, layers...),
+	}
+	manifest.SchemaVersion = 2
+	content, err := json.MarshalIndent(&manifest, "", "\t")
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+// getErrors decodes the error response in the body of resp.
+func getErrors(resp *reggie.Response) ([]errorInfo, error) {
+	var errorResponse struct {
+		Errors []errorInfo `json:"errors"`
+	}
+	if err := json.Unmarshal(resp.Body(), &errorResponse); err != nil {
+		return nil, fmt.Errorf("invalid error response %q: %v", resp.Body(), err)
+	}
+	return errorResponse.Errors, nil
+}
+
+// mentions reports whether the message or detail of the error refers to s.
+func (e errorInfo) mentions(s string) bool {
+	return strings.Contains(e.Message, s) || strings.Contains(string(e.Detail), s)
+}
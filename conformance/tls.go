@@ -0,0 +1,143 @@
+package conformance
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// newTLSConfig builds the TLS configuration of the client, trusting the
+// certificates in caFile in addition to the system roots, and presenting the
+// client certificate in certFile and keyFile for mutual TLS. It returns nil
+// if none of the files are given.
+func newTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	if caFile == "" && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+
+	if caFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("%s and %s must be set together", envVarTLSCertFile, envVarTLSKeyFile)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// normalizeRootURL adds a scheme to a root URL which has none, which is https
+// unless plain HTTP was explicitly allowed. A URL using plain HTTP to a host
+// other than the local machine is refused without that.
+func normalizeRootURL(rootURL string, plainHTTP bool) (string, error) {
+	if rootURL == "" {
+		return rootURL, nil
+	}
+	if !strings.Contains(rootURL, "://") {
+		if plainHTTP {
+			return "http://" + rootURL, nil
+		}
+		return "https://" + rootURL, nil
+	}
+	u, err := url.Parse(rootURL)
+	if err != nil || u.Scheme != "http" || plainHTTP || isLoopback(u.Hostname()) {
+		return rootURL, nil
+	}
+	return "", fmt.Errorf("%s uses plain HTTP to a remote host; set %s=1 if this is intended",
+		rootURL, envVarPlainHTTP)
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkTLS connects to the registry before any workflow runs, so that
+// problems with its certificate are reported as such rather than as a
+// failure of every spec. The request is sent with httpClient, the client of
+// the workflows, so it goes through the same proxy and TLS configuration.
+func checkTLS(rootURL string, httpClient *http.Client) error {
+	u, err := url.Parse(rootURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(rootURL, "/")+"/v2/", nil)
+	if err != nil {
+		return err
+	}
+	// any response at all means the TLS handshake succeeded
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return describeTLSError(u.Hostname(), err)
+	}
+	return resp.Body.Close()
+}
+
+// describeTLSError explains the most common certificate problems.
+func describeTLSError(hostname string, err error) error {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalid x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &unknownAuthority):
+		issuer := ""
+		if unknownAuthority.Cert != nil {
+			issuer = fmt.Sprintf(" (issued by %q)", unknownAuthority.Cert.Issuer.String())
+		}
+		return fmt.Errorf("TLS: the certificate chain of %s is not trusted%s; set %s to the CA bundle of the registry: %v",
+			hostname, issuer, envVarTLSCAFile, err)
+	case errors.As(err, &hostnameErr):
+		var names []string
+		if c := hostnameErr.Certificate; c != nil {
+			names = append(names, c.DNSNames...)
+			for _, ip := range c.IPAddresses {
+				names = append(names, ip.String())
+			}
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("TLS: the certificate of %s has no subject alternative names: %v", hostname, err)
+		}
+		return fmt.Errorf("TLS: the certificate of %s is only valid for %s: %v", hostname, strings.Join(names, ", "), err)
+	case errors.As(err, &invalid):
+		return fmt.Errorf("TLS: the certificate of %s is invalid: %v", hostname, err)
+	default:
+		return fmt.Errorf("TLS: could not connect to %s: %v", hostname, err)
+	}
+}
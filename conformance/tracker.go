@@ -0,0 +1,314 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bloodorangeio/reggie"
+	"github.com/go-resty/resty/v2"
+	godigest "github.com/opencontainers/go-digest"
+	imagespec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	blobResource resourceKind = iota
+	manifestResource
+)
+
+var (
+	// sweptMediaTypes are the media types of every manifest and index the
+	// workflows push
+	sweptMediaTypes = []string{
+		imagespec.MediaTypeImageManifest,
+		imagespec.MediaTypeImageIndex,
+		dockerManifestMediaType,
+		dockerManifestListMediaType,
+	}
+
+	referrersTagPattern = regexp.MustCompile(`^sha256-[a-f0-9]{64}$`)
+
+	// emptyJSONDigest is the digest of "{}", the config of many artifacts
+	emptyJSONDigest = godigest.FromBytes([]byte("{}")).String()
+)
+
+type (
+	resourceKind int
+
+	// trackedResource is a piece of content created in a registry by the
+	// suite, recorded so that it can be removed again at the end of the run
+	// with the client that created it.
+	trackedResource struct {
+		Client    *reggie.Client
+		Kind      resourceKind
+		Namespace string
+		Reference string
+		Digest    string
+	}
+
+	// resourceTracker records every blob and manifest successfully created by
+	// the clients so that teardown does not depend on individual specs
+	// completing.
+	resourceTracker struct {
+		mu        sync.Mutex
+		resources []trackedResource
+		seen      map[string]bool
+	}
+)
+
+func newResourceTracker() *resourceTracker {
+	return &resourceTracker{seen: make(map[string]bool)}
+}
+
+// afterResponse returns the resty response middleware of c, which inspects
+// every response for content created by a PUT or POST.
+func (t *resourceTracker) afterResponse(c *reggie.Client) func(*resty.Client, *resty.Response) error {
+	return func(_ *resty.Client, resp *resty.Response) error {
+		return t.inspect(c, resp)
+	}
+}
+
+func (t *resourceTracker) inspect(c *reggie.Client, resp *resty.Response) error {
+	req := resp.Request
+	if req == nil || req.RawRequest == nil {
+		return nil
+	}
+	if resp.StatusCode() < 200 || resp.StatusCode() >= 300 {
+		return nil
+	}
+	if req.Method != reggie.PUT && req.Method != reggie.POST {
+		return nil
+	}
+
+	u := req.RawRequest.URL
+	path := strings.TrimPrefix(u.Path, "/v2/")
+	query := u.Query()
+
+	if i := strings.LastIndex(path, "/manifests/"); i >= 0 && req.Method == reggie.PUT {
+		namespace, reference := path[:i], path[i+len("/manifests/"):]
+		digest := resp.Header().Get("Docker-Content-Digest")
+		if digest == "" {
+			if body, ok := req.Body.([]byte); ok {
+				digest = godigest.FromBytes(body).String()
+			}
+		}
+		t.add(trackedResource{Client: c, Kind: manifestResource, Namespace: namespace, Reference: reference, Digest: digest})
+		return nil
+	}
+
+	if i := strings.LastIndex(path, "/blobs/uploads"); i >= 0 {
+		namespace := path[:i]
+		digest := query.Get("digest")
+		if digest == "" {
+			digest = query.Get("mount")
+		}
+		if digest != "" && resp.StatusCode() == http.StatusCreated {
+			t.add(trackedResource{Client: c, Kind: blobResource, Namespace: namespace, Reference: digest, Digest: digest})
+		}
+	}
+
+	return nil
+}
+
+func (t *resourceTracker) add(r trackedResource) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := fmt.Sprintf("%p|%d|%s|%s|%s", r.Client, r.Kind, r.Namespace, r.Reference, r.Digest)
+	if t.seen[key] {
+		return
+	}
+	t.seen[key] = true
+	t.resources = append(t.resources, r)
+}
+
+// unwind deletes every tracked resource in reverse dependency order:
+// manifests first (most recent first), then the blobs they reference.
+// Blobs which other content may share are left alone, as the registry does
+// not tell whether an upload created them or they already existed.
+// Resources already removed by a teardown spec are simply not found again,
+// so failures are ignored and never fail the suite.
+func (t *resourceTracker) unwind() {
+	t.mu.Lock()
+	resources := t.resources
+	t.resources = nil
+	t.seen = make(map[string]bool)
+	t.mu.Unlock()
+
+	for i := len(resources) - 1; i >= 0; i-- {
+		if r := resources[i]; r.Kind == manifestResource {
+			deleteManifest(r.Client, r.Namespace, r.Reference, r.Digest)
+		}
+	}
+
+	deleted := map[string]bool{}
+	for i := len(resources) - 1; i >= 0; i-- {
+		r := resources[i]
+		if key := fmt.Sprintf("%p|%s@%s", r.Client, r.Namespace, r.Digest); r.Kind == blobResource && !deleted[key] && !isSharedBlob(r.Digest) {
+			deleteBlob(r.Client, r.Namespace, r.Digest)
+			deleted[key] = true
+		}
+	}
+}
+
+// deleteManifest removes a tag, if reference is one, and then the manifest
+// itself by digest. Registries differ in whether deleting one also removes
+// the other, so both are attempted.
+func deleteManifest(c *reggie.Client, namespace, reference, digest string) bool {
+	var ok bool
+	if reference != "" && reference != digest {
+		req := c.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<reference>",
+			reggie.WithName(namespace), reggie.WithReference(reference))
+		resp, err := c.Do(req)
+		ok = err == nil && resp.StatusCode() >= 200 && resp.StatusCode() < 300
+	}
+	if digest != "" {
+		req := c.NewRequest(reggie.DELETE, "/v2/<name>/manifests/<digest>",
+			reggie.WithName(namespace), reggie.WithDigest(digest))
+		resp, err := c.Do(req)
+		ok = ok || err == nil && resp.StatusCode() >= 200 && resp.StatusCode() < 300
+	}
+	return ok
+}
+
+func deleteBlob(c *reggie.Client, namespace, digest string) bool {
+	req := c.NewRequest(reggie.DELETE, "/v2/<name>/blobs/<digest>",
+		reggie.WithName(namespace), reggie.WithDigest(digest))
+	resp, err := c.Do(req)
+	return err == nil && resp.StatusCode() >= 200 && resp.StatusCode() < 300
+}
+
+// sweep deletes content left behind in namespace by previous runs that
+// crashed before teardown. Only the fixed tags and blobs used by the suite
+// are considered, with tagPrefix in front of the tags, along with the
+// referrers tag indexes of the suite's artifacts, and whatever the tagged
+// manifests reference.
+func sweep(namespace, tagPrefix string) (removed []string) {
+	for _, tag := range sweepTags() {
+		removed = append(removed, sweepManifest(namespace, tagPrefix+tag)...)
+	}
+
+	// the referrers tag of a subject is named after its digest, so these
+	// are recognized by the artifacts they list instead
+	for _, tag := range listTags(namespace) {
+		if !referrersTagPattern.MatchString(tag) {
+			continue
+		}
+		resp, ok := getSweptManifest(namespace, tag)
+		if !ok {
+			continue
+		}
+		var index referrersIndex
+		if err := json.Unmarshal(resp.Body(), &index); err != nil || !isSuiteReferrersIndex(index) {
+			continue
+		}
+		removed = append(removed, sweepManifest(namespace, tag)...)
+		subject := strings.Replace(tag, "-", ":", 1)
+		removed = append(removed, sweepManifest(namespace, subject)...)
+	}
+
+	for _, digest := range []string{testBlobADigest, testBlobBDigest} {
+		if deleteBlob(client, namespace, digest) {
+			removed = append(removed, fmt.Sprintf("%s@%s", namespace, digest))
+		}
+	}
+
+	return removed
+}
+
+// sweepManifest deletes the manifest or index which reference refers to,
+// the manifests an index lists, and the blobs they reference.
+func sweepManifest(namespace, reference string) (removed []string) {
+	resp, ok := getSweptManifest(namespace, reference)
+	if !ok {
+		return nil
+	}
+
+	digest := resp.Header().Get("Docker-Content-Digest")
+	if digest == "" {
+		digest = godigest.FromBytes(resp.Body()).String()
+	}
+	if deleteManifest(client, namespace, reference, digest) {
+		if reference == digest {
+			removed = append(removed, fmt.Sprintf("%s@%s", namespace, digest))
+		} else {
+			removed = append(removed, fmt.Sprintf("%s:%s (%s)", namespace, reference, digest))
+		}
+	}
+
+	// a manifest has a config and layers, an index has manifests
+	var content struct {
+		Config    imagespec.Descriptor   `json:"config"`
+		Layers    []imagespec.Descriptor `json:"layers"`
+		Manifests []imagespec.Descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(resp.Body(), &content); err != nil {
+		return removed
+	}
+	for _, d := range content.Manifests {
+		removed = append(removed, sweepManifest(namespace, d.Digest.String())...)
+	}
+	for _, d := range append([]imagespec.Descriptor{content.Config}, content.Layers...) {
+		if d.Digest == "" || isSharedBlob(d.Digest.String()) {
+			continue
+		}
+		if deleteBlob(client, namespace, d.Digest.String()) {
+			removed = append(removed, fmt.Sprintf("%s@%s", namespace, d.Digest))
+		}
+	}
+	return removed
+}
+
+// getSweptManifest fetches a manifest or index of any of the media types
+// the workflows push.
+func getSweptManifest(namespace, reference string) (*reggie.Response, bool) {
+	req := client.NewRequest(reggie.GET, "/v2/<name>/manifests/<reference>",
+		reggie.WithName(namespace), reggie.WithReference(reference)).
+		SetHeader("Accept", strings.Join(sweptMediaTypes, ", "))
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode() != http.StatusOK {
+		return nil, false
+	}
+	return resp, true
+}
+
+func listTags(namespace string) []string {
+	req := client.NewRequest(reggie.GET, "/v2/<name>/tags/list", reggie.WithName(namespace))
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode() != http.StatusOK {
+		return nil
+	}
+	return getTagList(resp)
+}
+
+// isSuiteReferrersIndex reports whether a referrers tag index was pushed by
+// the Referrers workflow, which always pushes a signature of its own type.
+func isSuiteReferrersIndex(index referrersIndex) bool {
+	for _, m := range index.Manifests {
+		if m.ArtifactType == signatureArtifactType {
+			return true
+		}
+	}
+	return false
+}
+
+// isSharedBlob reports whether a blob may be referenced by content other
+// than the suite's, and so must not be deleted.
+func isSharedBlob(digest string) bool {
+	return digest == emptyJSONDigest
+}
+
+// sweepTags returns the fixed tag names that the workflows push.
+func sweepTags() []string {
+	tags := []string{testTagName, emptyLayerTestTag, benchmarkTagName, mutableTagName,
+		dockerManifestTagName, dockerManifestListTagName, mirrorTagName}
+	for i := 0; i < 4; i++ {
+		tags = append(tags, fmt.Sprintf("test%d", i))
+	}
+	for _, name := range []string{"sbom", "signature", "zstd", "custom"} {
+		tags = append(tags, artifactTagPrefix+name)
+	}
+	return tags
+}
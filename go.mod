@@ -0,0 +1,3 @@
+module github.com/opencontainers/distribution-spec
+
+go 1.18
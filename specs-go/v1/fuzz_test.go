@@ -0,0 +1,119 @@
+// Copyright 2021 The Linux Foundation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// errorBodies are error responses as shown in detail.md, with the
+// placeholders filled in, and the variations registries are known to send.
+var errorBodies = []string{
+	`{"errors":[{"code":"UNAUTHORIZED","message":"authentication required","detail":"[{\"Type\":\"repository\",\"Name\":\"library/ubuntu\",\"Action\":\"pull\"}]"}]}`,
+	`{"errors":[{"code":"BLOB_UNKNOWN","message":"blob unknown to registry","detail":"sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"}]}`,
+	`{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown","detail":""}]}`,
+	`{"errors":[{"code":"NAME_UNKNOWN","message":"repository name not known to registry"}]}`,
+	`{"errors":[{"code":"DIGEST_INVALID","message":"provided digest did not match uploaded content"},{"code":"SIZE_INVALID","message":"provided length did not match content length"}]}`,
+	`{"errors":[{"code":"BLOB_UPLOAD_INVALID","message":"blob upload invalid","detail":{"reason":"out of order"}}]}`,
+	`{"errors":[{"code":"TOOMANYREQUESTS","message":"too many requests","detail":null}]}`,
+	`{"errors":[]}`,
+	`{"errors":null}`,
+	`{}`,
+	`{"errors":[{"code":"DENIED","message":"requested access to the resource is denied","detail":"éè"}]}`,
+}
+
+// tagListBodies are tag listings as shown in detail.md.
+var tagListBodies = []string{
+	`{"name":"library/ubuntu","tags":["latest","20.04","focal"]}`,
+	`{
+    "name": "myorg/myrepo",
+    "tags": [
+        "tagtest0",
+        "test0"
+    ]
+}`,
+	`{"name":"myorg/myrepo","tags":[]}`,
+	`{"name":"myorg/myrepo","tags":null}`,
+	`{"name":"myorg/myrepo"}`,
+}
+
+// repositoryListBodies are catalog listings as shown in detail.md.
+var repositoryListBodies = []string{
+	`{"repositories":["library/ubuntu","myorg/myrepo"]}`,
+	`{"repositories":[]}`,
+	`{"repositories":null}`,
+	`{}`,
+}
+
+// roundTrip decodes data into v, and if that succeeds, checks that encoding
+// v and decoding the result again yields the same encoding. The first
+// encoding may differ from data, e.g. in whitespace or escaping.
+func roundTrip(t *testing.T, data []byte, v, w interface{}) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encoding %#v: %v", v, err)
+	}
+	if err := json.Unmarshal(encoded, w); err != nil {
+		t.Fatalf("decoding %q, encoded from %q: %v", encoded, data, err)
+	}
+	reencoded, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("encoding %#v: %v", w, err)
+	}
+	if !bytes.Equal(encoded, reencoded) {
+		t.Fatalf("encoding is not stable: %q became %q", encoded, reencoded)
+	}
+}
+
+func FuzzErrorResponse(f *testing.F) {
+	for _, body := range errorBodies {
+		f.Add([]byte(body))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var er, decoded ErrorResponse
+		roundTrip(t, data, &er, &decoded)
+		if er.Error() != ErrRegistry {
+			t.Fatalf("Error() returned %q", er.Error())
+		}
+		if len(er.Detail()) != len(er.Errors) {
+			t.Fatalf("Detail() returned %d errors, expected %d", len(er.Detail()), len(er.Errors))
+		}
+	})
+}
+
+func FuzzTagList(f *testing.F) {
+	for _, body := range tagListBodies {
+		f.Add([]byte(body))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var tl, decoded TagList
+		roundTrip(t, data, &tl, &decoded)
+	})
+}
+
+func FuzzRepositoryList(f *testing.F) {
+	for _, body := range repositoryListBodies {
+		f.Add([]byte(body))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var rl, decoded RepositoryList
+		roundTrip(t, data, &rl, &decoded)
+	})
+}